@@ -1,11 +1,12 @@
 package reporter
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-
-	_ "github.com/mattn/go-sqlite3"
+	"sync"
+	"time"
 )
 
 const (
@@ -17,7 +18,7 @@ const (
 
 type Address struct {
 	Address string
-	Tokens  float64
+	Tokens  Currency
 }
 
 func (a Address) MarshalJSON() (text []byte, err error) {
@@ -25,38 +26,261 @@ func (a Address) MarshalJSON() (text []byte, err error) {
 	return json.Marshal(m)
 }
 
-type Addresses map[string]float64
+type Addresses map[string]Currency
+
+//MultiSignatureWallet is a wallet shared by several addresses
+type MultiSignatureWallet struct {
+	Address               string
+	MinimumSignatureCount int
+	Owners                []string
+	Balance               Balance
+}
+
+//Balance of an address or the whole network
+type Balance struct {
+	Total  Currency
+	Locked Currency
+}
+
+//Liquid returns the amount of tokens that are not time locked
+func (b Balance) Liquid() Currency {
+	return b.Total.Sub(b.Locked)
+}
+
+//addressMigrations are the schema migrations of the address store. The balances of the first versions
+//(the unlockhash table) were float amounts kept without a cursor, so they can't be carried over: the store
+//is rebuilt with a full rescan from the first block, since its cursor starts at 0, and the table is dropped.
+var addressMigrations = []Migration{
+	{1, `
+	create table if not exists balance (
+		address text not null primary key,
+		value text not null
+	);
+
+	create index if not exists balance_value_index on balance (value);
+
+	create table if not exists history (
+		rowid integer primary key,
+		address text not null,
+		height integer not null,
+		timestamp integer not null,
+		type text not null,
+		id text not null,
+		value text not null
+	);
+
+	create index if not exists history_address_index on history (address, height);
+	create index if not exists history_height_index on history (height);
+
+	create table if not exists timeline (
+		address text not null,
+		height integer not null,
+		timestamp integer not null,
+		value text not null,
+		primary key (address, height)
+	);
+
+	create index if not exists timeline_height_index on timeline (height);
+
+	create table if not exists block (
+		height integer not null primary key,
+		timestamp integer not null
+	);
+
+	create index if not exists block_timestamp_index on block (timestamp);
+
+	create table if not exists locked (
+		address text not null,
+		value text not null,
+		locktime integer not null,
+		height integer not null,
+		released integer
+	);
+
+	create index if not exists locked_address_index on locked (address);
+	create index if not exists locked_height_index on locked (height);
+	create index if not exists locked_released_index on locked (released);
+
+	create table if not exists multisig (
+		address text not null primary key,
+		minsigs integer not null,
+		height integer not null
+	);
+
+	create index if not exists multisig_height_index on multisig (height);
+
+	create table if not exists multisig_owner (
+		multisig text not null,
+		owner text not null,
+		primary key (multisig, owner)
+	);
+
+	create index if not exists multisig_owner_index on multisig_owner (owner);
+
+	create table if not exists swap (
+		id text not null primary key,
+		address text not null,
+		sender text not null,
+		receiver text not null,
+		hashedsecret text not null,
+		timelock integer not null,
+		value text not null,
+		height integer not null,
+		status text not null,
+		spent integer,
+		secret text not null default ''
+	);
+
+	create index if not exists swap_sender_index on swap (sender);
+	create index if not exists swap_receiver_index on swap (receiver);
+	create index if not exists swap_status_index on swap (status);
+	create index if not exists swap_height_index on swap (height);
+	create index if not exists swap_spent_index on swap (spent);
+	` + cursorSchema},
+	{2, `
+	create table if not exists supply (
+		id integer not null primary key,
+		total text not null,
+		locked text not null
+	);
+	`},
+	{3, `
+	create table if not exists checkpoint (
+		height integer not null,
+		address text not null,
+		value text not null,
+		primary key (height, address)
+	);
+
+	insert into checkpoint (height, address, value)
+	select c.height - 1, b.address, b.value from balance b, cursor c where c.height > 0
+	on conflict do nothing;
+	`},
+	{4, `
+	drop table if exists unlockhash;
+	`},
+}
+
+//checkpointInterval is the number of blocks between two copies of all the balances, so the balances at a
+//height are the last checkpoint before it with the timeline changes since
+const checkpointInterval = 10000
 
+//AddressRecorder keeps track of the addresses balances, blocks are applied in a single database transaction
+//which is committed every batchSize blocks or flushInterval, whatever comes first
 type AddressRecorder struct {
-	db *sql.DB
+	db            *Store
+	batch         *stmtTx
+	blocks        int
+	batchSize     int
+	flushInterval time.Duration
+	readOnly      bool
+
+	cancel context.CancelFunc
+	m      sync.Mutex
 }
 
-func NewAddressRecorder(p string) (*AddressRecorder, error) {
-	db, err := sql.Open("sqlite3", p)
+//NewAddressRecorder creates a new address recorder on the store of the given DSN (see OpenStore), the store
+//schema is migrated to the current version. A batchSize of 1 commits every block on its own
+func NewAddressRecorder(dsn string, batchSize int, flushInterval time.Duration) (*AddressRecorder, error) {
+	db, err := OpenStore(dsn)
 	if err != nil {
 		return nil, err
 	}
-	exec := `
-	create table if not exists unlockhash (
-		address text not null primary key,
-		value real
-	);
 
-	create index if not exists add_index on unlockhash (address);
-	create index if not exists value_index on unlockhash (value);
-	`
-	_, err = db.Exec(exec)
+	if err := db.Migrate(addressMigrations); err != nil {
+		return nil, err
+	}
+
+	recorder := &AddressRecorder{db: db, batchSize: batchSize, flushInterval: flushInterval}
+	return recorder, recorder.init()
+}
+
+//NewAddressReader opens the store of an address recorder that runs somewhere else, so the API can
+//be served by several instances from a shared database. The reader can't record blocks.
+func NewAddressReader(dsn string) (*AddressRecorder, error) {
+	db, err := OpenStore(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	return &AddressRecorder{db: db}, nil
+	if err := db.Check(addressMigrations); err != nil {
+		return nil, fmt.Errorf("%v, the address store must be created by the reporter first", err)
+	}
+
+	return &AddressRecorder{db: db, readOnly: true}, nil
+}
+
+func (r *AddressRecorder) init() error {
+	//start flusher routine
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	go func(ctx context.Context, d time.Duration) {
+		for {
+			select {
+			case <-time.After(d):
+				if err := r.flush(); err != nil {
+					log.Errorf("address recorder flush: %s", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx, r.flushInterval)
+
+	return nil
+}
+
+func (r *AddressRecorder) flush() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r._flush()
+}
+
+//_flush commits the pending blocks
+func (r *AddressRecorder) _flush() error {
+	if r.batch == nil {
+		return nil
+	}
+
+	err := r.batch.Commit()
+	r.batch = nil
+	r.blocks = 0
+	return err
 }
 
-func (r *AddressRecorder) unlockHashes(c *Condition) ([]string, error) {
-	var hashes []string
-	switch c.Type {
-	case NilCondtion:
+//abort discards the pending blocks, the cursor is not moved so they are recorded again on the next run
+func (r *AddressRecorder) abort() {
+	if r.batch != nil {
+		r.batch.Rollback()
+		r.batch = nil
+		r.blocks = 0
+	}
+}
+
+func (r *AddressRecorder) begin() (*stmtTx, error) {
+	if r.batch == nil {
+		if r.readOnly {
+			return nil, fmt.Errorf("address recorder is read only")
+		}
+
+		tx, err := r.db.begin()
+		if err != nil {
+			return nil, err
+		}
+
+		r.batch = tx
+	}
+
+	return r.batch, nil
+}
+
+//conditionOwner returns the address that owns an output with the given condition, where hash is the unlock hash
+//of the condition as computed by the explorer, it's derived from the condition if empty. An empty owner means
+//the output is not owned by any address.
+func conditionOwner(c *Condition, hash string) (string, error) {
+	switch data := c.Value().(type) {
+	case NilConditionData:
 		/*
 			Nil condition is funny
 
@@ -75,66 +299,70 @@ func (r *AddressRecorder) unlockHashes(c *Condition) ([]string, error) {
 			In that case, someone will redirect this fund (in another transaction) to a certian address, so i think
 			it's okay to ignore it
 		*/
-	case UnlockHashCondition:
-		data := c.UnlockHashData()
-		hashes = append(hashes, data.UnlockHash)
-	case TimeLockCondition:
-		data := c.TimeLockData()
-		subHashes, err := r.unlockHashes(&data.Condition)
-		if err != nil {
-			return nil, err
-		}
-		hashes = append(hashes, subHashes...)
-	case AtomicSwapCondition:
+		return "", nil
+	case UnlockHashConditionData:
+		return data.UnlockHash, nil
+	case TimeLockConditionData:
+		//the unlock hash of a time lock condition is the unlock hash of its inner condition
+		return conditionOwner(&data.Condition, hash)
+	case AtomicSwapConditionData:
 		/*
 			Atomic swap always come in 2 transactions. The first one (this one here)
 			defines the potential addresses that can receive the fund (source and dest)
 			then followed by another one that actually moves the fund to either the source (refund)
 			or the dest.
 
-			It means for us we can ignore this atomic swap condition for now, and rely on the second
-			transaction to actually do the move.
-
-			Of course during this time, the (fund) is actually locked (not liquid) and we might
-			have to process this differently if we need to keep track of the liquid vs non-liquid tokens
+			Until then the fund is owned by the contract itself, the contract address is the unlock hash of
+			the condition.
 		*/
-	case MultiSignatureCondition:
-		/*
-			Multisignature is kinda tricky, since non of the receiver hashes owns the fund until they spend it
-			so we need to build groups for those at some point where they can only spend money from that shared
-			fund.
+		if len(hash) == 0 {
+			return data.UnlockHash()
+		}
 
-			For now we will assume that each address owns the full amount.
+		return hash, nil
+	case MultiSignatureConditionData:
+		/*
+			None of the participants owns the fund of a multisignature output until they spend it, so the
+			fund is owned by the multisignature wallet itself. The wallet address is the unlock hash of
+			the condition, which is built from the (sorted) participants unlock hashes and the minimum
+			signature count.
 		*/
-		data := c.MultiSignatureCondition()
-		hashes = data.UnlockHashes
+		if len(hash) == 0 {
+			return data.UnlockHash()
+		}
+
+		return hash, nil
 	default:
-		return nil, fmt.Errorf("unhandled condition type: %v", c.Type)
+		return "", fmt.Errorf("unhandled condition type: %v", c.Type)
 	}
-
-	return hashes, nil
 }
 
-func (r *AddressRecorder) processInputOutputs(addresses Addresses, i []InputOutput, op float64) error {
+//processInputOutputs applies the input/outputs values to their owners, hashes are the unlock hashes of
+//the input/outputs as computed by the explorer if the input/outputs don't have one already
+func (r *AddressRecorder) processInputOutputs(addresses Addresses, i []InputOutput, hashes []string, op int) error {
 	for i, inout := range i {
-		var unlockHashes []string
-		if len(inout.UnlockHash) != 0 {
-			unlockHashes = []string{inout.UnlockHash}
-		} else {
+		hash := inout.UnlockHash
+		if len(hash) == 0 && i < len(hashes) {
+			hash = hashes[i]
+		}
+
+		owner := inout.UnlockHash
+		if len(owner) == 0 {
 			var err error
-			unlockHashes, err = r.unlockHashes(&inout.Condition)
+			owner, err = conditionOwner(&inout.Condition, hash)
 			if err != nil {
 				return fmt.Errorf("at index (%d): %s", i, err)
 			}
 		}
 
-		delta, err := inout.Value.Float64()
-		if err != nil {
-			return err
+		if len(owner) == 0 {
+			continue
 		}
 
-		for _, hash := range unlockHashes {
-			addresses[hash] += op * delta
+		if op == opAdd {
+			addresses[owner] = addresses[owner].Add(inout.Value)
+		} else {
+			addresses[owner] = addresses[owner].Sub(inout.Value)
 		}
 	}
 
@@ -144,72 +372,459 @@ func (r *AddressRecorder) processInputOutputs(addresses Addresses, i []InputOutp
 func (r *AddressRecorder) aggregate(addresses Addresses, txn *Transaction) error {
 	//updating transaction fees
 
-	if err := r.processInputOutputs(addresses, txn.RawTransaction.Data.CoinOutputs, opAdd); err != nil {
+	if err := r.processInputOutputs(addresses, txn.RawTransaction.Data.CoinOutputs, txn.CoinOutputUnlockHashes, opAdd); err != nil {
 		return fmt.Errorf("aggregate coinoutputs: %v", err)
 	}
 
-	if err := r.processInputOutputs(addresses, txn.CoinInputOutputs, opSub); err != nil {
+	if err := r.processInputOutputs(addresses, txn.CoinInputOutputs, nil, opSub); err != nil {
 		return fmt.Errorf("aggregate inputouts: %v", err)
 	}
 
 	return nil
 }
 
+//register keeps track of the participants of the multisignature wallets that receive outputs
+func (r *AddressRecorder) register(tx querier, blk *Block, txn *Transaction) error {
+	for i, output := range txn.RawTransaction.Data.CoinOutputs {
+		condition := output.Condition
+		if lock, ok := condition.Value().(TimeLockConditionData); ok {
+			condition = lock.Condition
+		}
+
+		data, ok := condition.Value().(MultiSignatureConditionData)
+		if !ok {
+			continue
+		}
+
+		var hash string
+		if i < len(txn.CoinOutputUnlockHashes) {
+			hash = txn.CoinOutputUnlockHashes[i]
+		}
+
+		address, err := conditionOwner(&condition, hash)
+		if err != nil {
+			return fmt.Errorf("at index (%d): %v", i, err)
+		}
+
+		_, err = tx.Exec(
+			"insert into multisig (address, minsigs, height) values (?, ?, ?) on conflict do nothing;",
+			address, data.MinimumSignatureCount, blk.Height,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, owner := range data.UnlockHashes {
+			_, err := tx.Exec(
+				"insert into multisig_owner (multisig, owner) values (?, ?) on conflict do nothing;",
+				address, owner,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//lock keeps track of the outputs that are still time locked at the given block, and returns the
+//amount they lock
+func (r *AddressRecorder) lock(tx querier, blk *Block, txn *Transaction) (Currency, error) {
+	var locked Currency
+	for i, output := range txn.RawTransaction.Data.CoinOutputs {
+		data, ok := output.Condition.Value().(TimeLockConditionData)
+		if !ok || data.Unlocked(blk) {
+			continue
+		}
+
+		var hash string
+		if i < len(txn.CoinOutputUnlockHashes) {
+			hash = txn.CoinOutputUnlockHashes[i]
+		}
+
+		owner, err := conditionOwner(&data.Condition, hash)
+		if err != nil {
+			return locked, err
+		} else if len(owner) == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(
+			"insert into locked (address, value, locktime, height) values (?, ?, ?, ?);",
+			owner, output.Value.String(), data.LockTime, blk.Height,
+		)
+
+		if err != nil {
+			return locked, err
+		}
+
+		locked = locked.Add(output.Value)
+	}
+
+	return locked, nil
+}
+
+//release marks the locked outputs as released once the chain passes their lock time, and returns
+//the amount they release
+func (r *AddressRecorder) release(tx querier, blk *Block) (Currency, error) {
+	where := `released is null and (
+		(locktime < ? and locktime <= ?) or (locktime >= ? and locktime <= ?)
+	)`
+	args := []interface{}{LockTimeMinTimestampValue, blk.Height, LockTimeMinTimestampValue, blk.RawBlock.Timestamp}
+
+	released, err := r.sum(tx, "select value from locked where "+where+";", args...)
+	if err != nil {
+		return released, err
+	}
+
+	if _, err := tx.Exec("update locked set released = ? where "+where+";", append([]interface{}{blk.Height}, args...)...); err != nil {
+		return released, err
+	}
+
+	//released outputs are only needed as long as their block can be rolled back
+	_, err = tx.Exec("delete from locked where released <= ?;", blk.Height-MaxReorgDepth)
+	return released, err
+}
+
 //Get tokens on this address
-func (r *AddressRecorder) Get(address string) (float64, error) {
-	row := r.db.QueryRow("select value from unlockhash where address = ?;", address)
-	var value float64
+func (r *AddressRecorder) Get(address string) (Currency, error) {
+	return r.get(r.db, address)
+}
+
+func (r *AddressRecorder) get(q querier, address string) (Currency, error) {
+	row := q.QueryRow("select value from balance where address = ?;", address)
+	var value string
 	if err := row.Scan(&value); err == sql.ErrNoRows {
-		return 0, nil
+		return Currency{}, nil
 	} else if err != nil {
-		return 0, err
+		return Currency{}, err
 	}
 
-	return value, nil
+	return parseSortable(value)
 }
 
-func (r *AddressRecorder) set(address string, value float64) error {
-	_, err := r.db.Exec("insert or replace into unlockhash (address, value) values (?, ?);", address, value)
+func (r *AddressRecorder) set(q querier, address string, value Currency) error {
+	_, err := q.Exec(
+		"insert into balance (address, value) values (?, ?) on conflict (address) do update set value = excluded.value;",
+		address, value.sortable(),
+	)
 	return err
 }
 
-//Record record a block on the address recorder
+//Cursor returns the height of the next block to record, pending blocks are committed first
+func (r *AddressRecorder) Cursor() (int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if err := r._flush(); err != nil {
+		return 0, err
+	}
+
+	return getCursor(r.db)
+}
+
+//Record record a block on the address recorder, the block is applied atomically with the cursor. If it fails
+//all the pending blocks of the batch are discarded.
 func (r *AddressRecorder) Record(blk *Block) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(tx, blk); err != nil {
+		r.abort()
+		return err
+	}
+
+	if err := setCursor(tx, blk.Height+1); err != nil {
+		r.abort()
+		return err
+	}
+
+	r.blocks++
+	if r.blocks >= r.batchSize {
+		return r._flush()
+	}
+
+	return nil
+}
+
+func (r *AddressRecorder) record(tx querier, blk *Block) error {
+	//the supply is read before any change of the block, so it's computed without them the first time
+	supply, err := r.supply(tx)
+	if err != nil {
+		return fmt.Errorf("supply: %v", err)
+	}
+
 	addresses := Addresses{}
 
 	//add miner fees
-	if err := r.processInputOutputs(addresses, blk.RawBlock.MinerPayouts, opAdd); err != nil {
+	payouts := Addresses{}
+	if err := r.processInputOutputs(payouts, blk.RawBlock.MinerPayouts, nil, opAdd); err != nil {
 		return fmt.Errorf("process minerfees: %v", err)
 	}
 
+	if err := r.history(tx, blk, addresses, payouts, HistoryMinerPayout, blk.ID); err != nil {
+		return fmt.Errorf("process minerfees: history: %v", err)
+	}
+
 	for i, txn := range blk.Transactions {
-		if err := r.aggregate(addresses, &txn); err != nil {
+		deltas := Addresses{}
+		if err := r.aggregate(deltas, &txn); err != nil {
 			return fmt.Errorf("transaction (%d): %v", i, err)
 		}
+
+		if err := r.history(tx, blk, addresses, deltas, HistoryTransaction, txn.ID); err != nil {
+			return fmt.Errorf("transaction (%d): history: %v", i, err)
+		}
+
+		locked, err := r.lock(tx, blk, &txn)
+		if err != nil {
+			return fmt.Errorf("transaction (%d): lock: %v", i, err)
+		}
+
+		supply.Locked = supply.Locked.Add(locked)
+
+		if err := r.register(tx, blk, &txn); err != nil {
+			return fmt.Errorf("transaction (%d): register multisig: %v", i, err)
+		}
+
+		if err := r.openSwaps(tx, blk, &txn); err != nil {
+			return fmt.Errorf("transaction (%d): open atomic swaps: %v", i, err)
+		}
+
+		if err := r.spendSwaps(tx, blk, &txn); err != nil {
+			return fmt.Errorf("transaction (%d): spend atomic swaps: %v", i, err)
+		}
+	}
+
+	released, err := r.release(tx, blk)
+	if err != nil {
+		return fmt.Errorf("release locked tokens: %v", err)
+	}
+
+	supply.Locked = supply.Locked.Sub(released)
+
+	_, err = tx.Exec(
+		"insert into block (height, timestamp) values (?, ?) on conflict (height) do update set timestamp = excluded.timestamp;",
+		blk.Height, blk.RawBlock.Timestamp,
+	)
+	if err != nil {
+		return err
 	}
 
 	for add, delta := range addresses {
-		current, err := r.Get(add)
+		current, err := r.get(tx, add)
 		if err != nil {
 			return err
 		}
 
-		if err := r.set(add, current+delta); err != nil {
+		balance := current.Add(delta)
+		if err := r.set(tx, add, balance); err != nil {
+			return err
+		}
+
+		supply.Total = supply.Total.Add(delta)
+
+		_, err = tx.Exec(
+			`insert into timeline (address, height, timestamp, value) values (?, ?, ?, ?)
+			on conflict (address, height) do update set timestamp = excluded.timestamp, value = excluded.value;`,
+			add, blk.Height, blk.RawBlock.Timestamp, balance.sortable(),
+		)
+		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if blk.Height%checkpointInterval == 0 {
+		//the where clause lets sqlite tell the upsert from a join constraint, and the cast types the height
+		//for postgres which takes an untyped parameter in a select list as text
+		_, err := tx.Exec(
+			`insert into checkpoint (height, address, value) select cast(? as bigint), address, value from balance where true
+			on conflict (height, address) do update set value = excluded.value;`,
+			blk.Height,
+		)
+		if err != nil {
+			return fmt.Errorf("checkpoint: %v", err)
+		}
+	}
+
+	return r.setSupply(tx, supply)
+}
+
+//Rollback reverts the balance changes of all blocks with height above h, the rollback is committed
+//with the pending blocks
+func (r *AddressRecorder) Rollback(h int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.rollback(tx, h); err != nil {
+		r.abort()
+		return err
+	}
+
+	return r._flush()
 }
 
-//Close the recorder, any calls to record after that will fail
+func (r *AddressRecorder) rollback(tx querier, h int64) error {
+	supply, err := r.supply(tx)
+	if err != nil {
+		return fmt.Errorf("supply: %v", err)
+	}
+
+	rows, err := tx.Query("select distinct address from timeline where height > ?;", h)
+	if err != nil {
+		return err
+	}
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			rows.Close()
+			return err
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	//restore the balances from the last timeline point that is kept
+	for _, add := range addresses {
+		current, err := r.get(tx, add)
+		if err != nil {
+			return err
+		}
+
+		balance, err := r.balanceAt(tx, add, h)
+		if err != nil {
+			return err
+		}
+
+		if err := r.set(tx, add, balance); err != nil {
+			return err
+		}
+
+		supply.Total = supply.Total.Add(balance.Sub(current))
+	}
+
+	//the outputs locked by the reverted blocks are removed, and the ones they released are locked again
+	relocked, err := r.sum(tx, "select value from locked where released > ? and height <= ?;", h, h)
+	if err != nil {
+		return err
+	}
+
+	unlocked, err := r.sum(tx, "select value from locked where released is null and height > ?;", h)
+	if err != nil {
+		return err
+	}
+
+	supply.Locked = supply.Locked.Add(relocked).Sub(unlocked)
+	if err := r.setSupply(tx, supply); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from timeline where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from checkpoint where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from history where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from block where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from locked where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("update locked set released = null where released > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from swap where height > ?;", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("update swap set status = ?, spent = null, secret = '' where spent > ?;", SwapOpen, h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from multisig_owner where multisig in (select address from multisig where height > ?);", h); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from multisig where height > ?;", h); err != nil {
+		return err
+	}
+
+	cursor, err := getCursor(tx)
+	if err != nil || cursor <= h+1 {
+		return err
+	}
+
+	return setCursor(tx, h+1)
+}
+
+//Close the recorder, and commits the pending blocks. Any calls to record after that will fail
 func (r *AddressRecorder) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+
 	return r.db.Close()
 }
 
 //Addresses returns addresses
-func (r *AddressRecorder) Addresses(over float64, page, size int) ([]Address, error) {
-	rows, err := r.db.Query("select address, value from unlockhash where value >= ? order by value desc limit ? offset ?;", over, size, page*size)
+func (r *AddressRecorder) Addresses(over Currency, page, size int) ([]Address, error) {
+	return r.addresses("select address, value from balance where value >= ? order by value desc, address limit ? offset ?;", over.sortable(), size, page*size)
+}
+
+//AddressesAt returns addresses sorted by their balances after the block at height h, the balances are the
+//ones of the last checkpoint before h updated with the timeline points after the checkpoint
+func (r *AddressRecorder) AddressesAt(over Currency, h int64, page, size int) ([]Address, error) {
+	return r.addresses(
+		`with c as (
+			select coalesce(max(height), -1) as height from checkpoint where height <= ?
+		), l as (
+			select address, max(height) as height from timeline
+			where height > (select height from c) and height <= ? group by address
+		)
+		select address, value from (
+			select t.address, t.value from timeline t join l on t.address = l.address and t.height = l.height
+			union all
+			select p.address, p.value from checkpoint p
+			where p.height = (select height from c) and not exists (select 1 from l where l.address = p.address)
+		) a where value >= ? order by value desc, address limit ? offset ?;`,
+		h, h, over.sortable(), size, page*size,
+	)
+}
+
+func (r *AddressRecorder) addresses(query string, args ...interface{}) ([]Address, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -220,9 +835,12 @@ func (r *AddressRecorder) Addresses(over float64, page, size int) ([]Address, er
 
 	for rows.Next() {
 		var address Address
-		// var address string
-		// var value float64
-		if err := rows.Scan(&address.Address, &address.Tokens); err != nil {
+		var value string
+		if err := rows.Scan(&address.Address, &value); err != nil {
+			return nil, err
+		}
+
+		if address.Tokens, err = parseSortable(value); err != nil {
 			return nil, err
 		}
 
@@ -231,3 +849,161 @@ func (r *AddressRecorder) Addresses(over float64, page, size int) ([]Address, er
 
 	return addresses, nil
 }
+
+func (r *AddressRecorder) sum(q querier, query string, args ...interface{}) (Currency, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	defer rows.Close()
+
+	var total Currency
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return total, err
+		}
+
+		amount, err := ParseCurrency(value)
+		if err != nil {
+			return total, err
+		}
+
+		total = total.Add(amount)
+	}
+
+	return total, rows.Err()
+}
+
+//Balance returns the total and locked tokens on this address
+func (r *AddressRecorder) Balance(address string) (Balance, error) {
+	var balance Balance
+	var err error
+	if balance.Total, err = r.Get(address); err != nil {
+		return balance, err
+	}
+
+	balance.Locked, err = r.sum(r.db, "select value from locked where address = ? and released is null;", address)
+	return balance, err
+}
+
+//Supply returns the total and locked tokens on the network
+func (r *AddressRecorder) Supply() (Balance, error) {
+	return r.supply(r.db)
+}
+
+//supply returns the total and locked tokens on the network. They are kept up to date by the recorded
+//blocks, and computed from all balances and locked outputs if the store has no supply yet.
+func (r *AddressRecorder) supply(q querier) (Balance, error) {
+	var balance Balance
+	var total, locked string
+	row := q.QueryRow("select total, locked from supply where id = 0;")
+	if err := row.Scan(&total, &locked); err == nil {
+		if balance.Total, err = ParseCurrency(total); err != nil {
+			return balance, err
+		}
+
+		balance.Locked, err = ParseCurrency(locked)
+		return balance, err
+	} else if err != sql.ErrNoRows {
+		return balance, err
+	}
+
+	rows, err := q.Query("select value from balance;")
+	if err != nil {
+		return balance, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return balance, err
+		}
+
+		amount, err := parseSortable(value)
+		if err != nil {
+			return balance, err
+		}
+
+		balance.Total = balance.Total.Add(amount)
+	}
+
+	if err := rows.Err(); err != nil {
+		return balance, err
+	}
+
+	balance.Locked, err = r.sum(q, "select value from locked where released is null;")
+	return balance, err
+}
+
+func (r *AddressRecorder) setSupply(q querier, balance Balance) error {
+	_, err := q.Exec(
+		"insert into supply (id, total, locked) values (0, ?, ?) on conflict (id) do update set total = excluded.total, locked = excluded.locked;",
+		balance.Total.String(), balance.Locked.String(),
+	)
+	return err
+}
+
+//MultiSignatureWallets returns the multisignature wallets the given address participates in
+func (r *AddressRecorder) MultiSignatureWallets(address string) ([]MultiSignatureWallet, error) {
+	rows, err := r.db.Query(
+		"select address, minsigs from multisig where address in (select multisig from multisig_owner where owner = ?) order by address;",
+		address,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []MultiSignatureWallet
+	for rows.Next() {
+		var wallet MultiSignatureWallet
+		if err := rows.Scan(&wallet.Address, &wallet.MinimumSignatureCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		wallets = append(wallets, wallet)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range wallets {
+		wallet := &wallets[i]
+		if wallet.Owners, err = r.owners(wallet.Address); err != nil {
+			return nil, err
+		}
+
+		if wallet.Balance, err = r.Balance(wallet.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	return wallets, nil
+}
+
+func (r *AddressRecorder) owners(multisig string) ([]string, error) {
+	rows, err := r.db.Query("select owner from multisig_owner where multisig = ? order by owner;", multisig)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, owner)
+	}
+
+	return owners, rows.Err()
+}
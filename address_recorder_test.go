@@ -0,0 +1,179 @@
+package reporter
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//postgresTestDSN is the environment variable with the DSN of a postgres database for the tests, the
+//postgres tests are skipped without it
+const postgresTestDSN = "REPORTER_TEST_POSTGRES"
+
+//testPostgresStore returns the DSN of a new schema of the test postgres database, the schema is
+//dropped at the end of the test
+func testPostgresStore(t *testing.T) string {
+	dsn := os.Getenv(postgresTestDSN)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", postgresTestDSN)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	schema := fmt.Sprintf("reporter_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec("create schema " + schema + ";"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		defer db.Close()
+		if _, err := db.Exec("drop schema " + schema + " cascade;"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+
+	return dsn + separator + "search_path=" + schema
+}
+
+func TestAddressRecorder(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testAddressRecorder(t, "sqlite://"+filepath.Join(t.TempDir(), "addresses.db"))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		testAddressRecorder(t, testPostgresStore(t))
+	})
+}
+
+func testAddressRecorder(t *testing.T, dsn string) {
+	recorder, err := NewAddressRecorder(dsn, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	//the genesis block is a checkpoint
+	for _, blk := range []*Block{
+		seriesBlock(t, 0, 1500000000, "0", TransactionVersionOne, "", "1000", ""),
+		seriesBlock(t, 1, 1500000120, "100", TransactionVersionOne, "", "500", ""),
+		seriesBlock(t, 2, 1500000240, "100", TransactionVersionOne, "", "200", ""),
+	} {
+		if err := recorder.Record(blk); err != nil {
+			t.Fatalf("record block %d: %s", blk.Height, err)
+		}
+	}
+
+	names := map[string]string{testUnlockHash1: "1", testUnlockHash2: "2", testUnlockHash3: "3"}
+	expect := func(name string, expected string, query func() ([]Address, error)) {
+		t.Helper()
+		addresses, err := query()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		var actual []string
+		for _, address := range addresses {
+			actual = append(actual, fmt.Sprintf("%s=%s", names[address.Address], address.Tokens))
+		}
+
+		if strings.Join(actual, " ") != expected {
+			t.Errorf("got %s %v, expecting %s", name, actual, expected)
+		}
+	}
+
+	balances := func() ([]Address, error) {
+		return recorder.Addresses(Currency{}, 0, 10)
+	}
+
+	balancesAt := func(h int64) func() ([]Address, error) {
+		return func() ([]Address, error) {
+			return recorder.AddressesAt(Currency{}, h, 0, 10)
+		}
+	}
+
+	expect("balances", "2=1700 3=200", balances)
+	expect("checkpoint balances", "2=1000 3=0", balancesAt(0))
+	expect("balances at 1", "2=1500 3=100", balancesAt(1))
+
+	if err := recorder.Rollback(0); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("balances after rollback", "2=1000 3=0", balances)
+	expect("checkpoint balances after rollback", "2=1000 3=0", balancesAt(0))
+
+	if cursor, err := recorder.Cursor(); err != nil || cursor != 1 {
+		t.Errorf("got cursor %d (%v) after rollback, expecting 1", cursor, err)
+	}
+
+	if supply, err := recorder.Supply(); err != nil || supply.Total.String() != "1000" {
+		t.Errorf("got supply %s (%v) after rollback, expecting 1000", supply.Total, err)
+	}
+
+	//the block of the new branch is recorded over the reverted one
+	if err := recorder.Record(seriesBlock(t, 1, 1500000120, "50", TransactionVersionOne, "", "300", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("balances of the new branch", "2=1300 3=50", balances)
+	expect("balances of the new branch at 1", "2=1300 3=50", balancesAt(1))
+}
+
+func TestAddressRecorderFirstVersion(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "addresses.db")
+
+	//the balances of the first versions
+	db, err := sql.Open("sqlite3", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+	create table unlockhash (address text not null primary key, value real);
+	insert into unlockhash (address, value) values ('` + testUnlockHash1 + `', 1000);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewAddressRecorder(p, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	//the store is rebuilt from the first block
+	if cursor, err := recorder.Cursor(); err != nil || cursor != 0 {
+		t.Errorf("got cursor %d (%v), expecting 0", cursor, err)
+	}
+
+	var count int
+	if err := recorder.db.QueryRow("select count(*) from sqlite_master where name = 'unlockhash';").Scan(&count); err != nil {
+		t.Fatal(err)
+	} else if count != 0 {
+		t.Error("the unlockhash table of the first versions is not dropped")
+	}
+}
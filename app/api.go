@@ -1,9 +1,12 @@
 package app
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Jumpscale/reporter"
 	"github.com/gin-gonic/gin"
@@ -17,7 +20,12 @@ func jsonAction(action func(ctx *gin.Context) (interface{}, error)) func(ctx *gi
 
 		enc := json.NewEncoder(ctx.Writer)
 		if err != nil {
-			ctx.Writer.WriteHeader(http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if _, ok := err.(requestError); ok {
+				status = http.StatusBadRequest
+			}
+
+			ctx.Writer.WriteHeader(status)
 			if eerr := enc.Encode(err); eerr != nil {
 				log.Errorf("failed to encode error (%s): %s", err, eerr)
 			}
@@ -31,9 +39,27 @@ func jsonAction(action func(ctx *gin.Context) (interface{}, error)) func(ctx *gi
 	}
 }
 
+//requestError is an error of the request query params, it's returned with a 400 status
+type requestError string
+
+func (e requestError) Error() string {
+	return string(e)
+}
+
+//maxPageSize is the max number of entries of a page
+const maxPageSize = 1000
+
 type API struct {
-	InfluxRecorder  *reporter.InfluxRecorder
+	//SeriesRecorder serves the height and tokens endpoints, it's the influx or the embedded recorder
+	SeriesRecorder  reporter.TimeSeries
 	AddressRecorder *reporter.AddressRecorder
+	OutputRecorder  *reporter.OutputRecorder
+	//BlockStakeRecorder block stakes have no unit, they are returned as integers
+	BlockStakeRecorder *reporter.BlockStakeRecorder
+	//Unit of the amounts returned by the API
+	Unit reporter.Unit
+	//Spool of the failed influxdb writes, its size and age are served as metrics
+	Spool *reporter.Spool
 }
 
 func (a *API) Run(listen string) error {
@@ -42,47 +68,398 @@ func (a *API) Run(listen string) error {
 	engine.GET("height", jsonAction(a.height))
 	engine.GET("tokens/total", jsonAction(a.total))
 	engine.GET("tokens/transacted", jsonAction(a.transacted))
+	engine.GET("tokens/minted", jsonAction(a.minted))
 	engine.GET("address", jsonAction(a.addresses))
+	engine.GET("tokens/supply", jsonAction(a.supply))
 	engine.GET("address/:address", jsonAction(a.address))
+	engine.GET("address/:address/balance", jsonAction(a.balance))
+	engine.GET("address/:address/multisig", jsonAction(a.multisig))
+	engine.GET("address/:address/swaps", jsonAction(a.addressSwaps))
+	engine.GET("address/:address/history", jsonAction(a.history))
+	engine.GET("address/:address/timeline", jsonAction(a.timeline))
+	engine.GET("swaps", jsonAction(a.swaps))
+	engine.GET("swaps/volume", jsonAction(a.swapVolume))
+
+	//the outputs and block stakes are only kept by the reporter that scans the chain
+	if a.OutputRecorder != nil {
+		engine.GET("address/:address/outputs", jsonAction(a.outputs))
+		engine.GET("address/:address/outputs/balance", jsonAction(a.outputsBalance))
+	}
+
+	if a.BlockStakeRecorder != nil {
+		engine.GET("blockstakes", jsonAction(a.blockStakes))
+		engine.GET("blockstakes/total", jsonAction(a.blockStakesTotal))
+		engine.GET("address/:address/blockstakes", jsonAction(a.addressBlockStakes))
+	}
+
+	if a.Spool != nil {
+		engine.GET("metrics", a.metrics)
+	}
 
 	return engine.Run(listen)
 }
 
+//pagination parses the page and size query params, the size is from 1 to maxPageSize and the page
+//is 0 or more
+func pagination(ctx *gin.Context) (page, size int, err error) {
+	value, err := strconv.ParseInt(ctx.DefaultQuery("size", "20"), 10, 32)
+	if err != nil || value <= 0 || value > maxPageSize {
+		return 0, 0, requestError(fmt.Sprintf("invalid size '%s', expecting 1 to %d", ctx.Query("size"), maxPageSize))
+	}
+	size = int(value)
+
+	value, err = strconv.ParseInt(ctx.DefaultQuery("page", "0"), 10, 32)
+	if err != nil || value < 0 {
+		return 0, 0, requestError(fmt.Sprintf("invalid page '%s', expecting 0 or more", ctx.Query("page")))
+	}
+	page = int(value)
+
+	return page, size, nil
+}
+
+//at parses the at query param, which is either a block height or an RFC3339 time, and returns
+//the block height it refers to. at is false if the query param is not set
+func (a *API) at(ctx *gin.Context) (h int64, at bool, err error) {
+	value, ok := ctx.GetQuery("at")
+	if !ok {
+		return 0, false, nil
+	}
+
+	if h, err = strconv.ParseInt(value, 10, 64); err == nil {
+		return h, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid at '%s', expecting a block height or an RFC3339 time", value)
+	}
+
+	h, err = a.AddressRecorder.HeightAt(t)
+	return h, true, err
+}
+
+//amount formats a currency as a json number in the API unit
+func (a *API) amount(c reporter.Currency, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Number(a.Unit.Format(c)), nil
+}
+
+//balanceObject formats a balance in the API unit
+func (a *API) balanceObject(b reporter.Balance, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]json.Number{
+		"total":  json.Number(a.Unit.Format(b.Total)),
+		"locked": json.Number(a.Unit.Format(b.Locked)),
+		"liquid": json.Number(a.Unit.Format(b.Liquid())),
+	}, nil
+}
+
 func (a *API) height(ctx *gin.Context) (interface{}, error) {
-	return a.InfluxRecorder.Height()
+	return a.SeriesRecorder.Height()
 }
 
 func (a *API) total(ctx *gin.Context) (interface{}, error) {
-	return a.InfluxRecorder.TotalTokens()
+	return a.amount(a.SeriesRecorder.TotalTokens())
 }
 
 func (a *API) transacted(ctx *gin.Context) (interface{}, error) {
 	period := ctx.DefaultQuery("period", "1h")
 	//TODO: validate given period
-	return a.InfluxRecorder.TransactedToken(reporter.Period(period))
+	return a.amount(a.SeriesRecorder.TransactedToken(reporter.Period(period)))
+}
+
+func (a *API) minted(ctx *gin.Context) (interface{}, error) {
+	issuances, err := a.SeriesRecorder.MintedTokens(reporter.Period(ctx.Query("period")))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, issuance := range issuances {
+		results = append(results, map[string]interface{}{
+			"height":    issuance.Height,
+			"timestamp": issuance.Timestamp,
+			"minted":    json.Number(a.Unit.Format(issuance.Minted)),
+			"burned":    json.Number(a.Unit.Format(issuance.Burned)),
+		})
+	}
+
+	return results, nil
+}
+
+func (a *API) supply(ctx *gin.Context) (interface{}, error) {
+	return a.balanceObject(a.AddressRecorder.Supply())
 }
 
 func (a *API) addresses(ctx *gin.Context) (interface{}, error) {
-	var over float64
-	var size int64
-	var page int64
+	over, err := a.Unit.Parse(ctx.DefaultQuery("over", "0"))
+	if err != nil {
+		return nil, err
+	}
 
-	var err error
-	if over, err = strconv.ParseFloat(ctx.DefaultQuery("over", "0"), 64); err != nil {
+	page, size, err := pagination(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	if size, err = strconv.ParseInt(ctx.DefaultQuery("size", "20"), 10, 32); err != nil {
+	h, at, err := a.at(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	if page, err = strconv.ParseInt(ctx.DefaultQuery("page", "0"), 10, 32); err != nil {
+	var addresses []reporter.Address
+	if at {
+		addresses, err = a.AddressRecorder.AddressesAt(over, h, page, size)
+	} else {
+		addresses, err = a.AddressRecorder.Addresses(over, page, size)
+	}
+
+	if err != nil {
 		return nil, err
 	}
 
-	return a.AddressRecorder.Addresses(over, int(page), int(size))
+	var results [][2]interface{}
+	for _, address := range addresses {
+		results = append(results, [2]interface{}{address.Address, json.Number(a.Unit.Format(address.Tokens))})
+	}
+
+	return results, nil
 }
 
 func (a *API) address(ctx *gin.Context) (interface{}, error) {
-	return a.AddressRecorder.Get(ctx.Param("address"))
+	h, at, err := a.at(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if at {
+		return a.amount(a.AddressRecorder.BalanceAt(ctx.Param("address"), h))
+	}
+
+	return a.amount(a.AddressRecorder.Get(ctx.Param("address")))
+}
+
+func (a *API) balance(ctx *gin.Context) (interface{}, error) {
+	return a.balanceObject(a.AddressRecorder.Balance(ctx.Param("address")))
+}
+
+func (a *API) multisig(ctx *gin.Context) (interface{}, error) {
+	wallets, err := a.AddressRecorder.MultiSignatureWallets(ctx.Param("address"))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, wallet := range wallets {
+		balance, _ := a.balanceObject(wallet.Balance, nil)
+		results = append(results, map[string]interface{}{
+			"address":               wallet.Address,
+			"minimumsignaturecount": wallet.MinimumSignatureCount,
+			"owners":                wallet.Owners,
+			"balance":               balance,
+		})
+	}
+
+	return results, nil
+}
+
+func (a *API) swapObjects(swaps []reporter.AtomicSwap, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, swap := range swaps {
+		results = append(results, map[string]interface{}{
+			"id":           swap.ID,
+			"address":      swap.Address,
+			"sender":       swap.Sender,
+			"receiver":     swap.Receiver,
+			"hashedsecret": swap.HashedSecret,
+			"timelock":     swap.TimeLock,
+			"value":        json.Number(a.Unit.Format(swap.Value)),
+			"height":       swap.Height,
+			"status":       swap.Status,
+			"spent":        swap.Spent,
+			"secret":       swap.Secret,
+		})
+	}
+
+	return results, nil
+}
+
+func (a *API) swaps(ctx *gin.Context) (interface{}, error) {
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.swapObjects(a.AddressRecorder.OpenSwaps(page, size))
+}
+
+func (a *API) addressSwaps(ctx *gin.Context) (interface{}, error) {
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.swapObjects(a.AddressRecorder.Swaps(ctx.Param("address"), page, size))
+}
+
+func (a *API) swapVolume(ctx *gin.Context) (interface{}, error) {
+	return a.amount(a.AddressRecorder.SwapVolume())
+}
+
+func (a *API) outputs(ctx *gin.Context) (interface{}, error) {
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := a.OutputRecorder.Outputs(ctx.Param("address"), page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, output := range outputs {
+		results = append(results, map[string]interface{}{
+			"id":         output.ID,
+			"value":      json.Number(a.Unit.Format(output.Value)),
+			"unlockhash": output.UnlockHash,
+			"condition":  output.Condition,
+			"height":     output.Height,
+		})
+	}
+
+	return results, nil
+}
+
+func (a *API) outputsBalance(ctx *gin.Context) (interface{}, error) {
+	return a.amount(a.OutputRecorder.Balance(ctx.Param("address")))
+}
+
+func (a *API) history(ctx *gin.Context) (interface{}, error) {
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := a.AddressRecorder.History(ctx.Param("address"), page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for _, entry := range entries {
+		amount := entry.Value
+		if amount.Sign() < 0 {
+			amount = reporter.Currency{}.Sub(amount)
+		}
+
+		results = append(results, map[string]interface{}{
+			"height":    entry.Height,
+			"timestamp": entry.Timestamp,
+			"type":      entry.Type,
+			"id":        entry.ID,
+			"direction": entry.Direction(),
+			"amount":    json.Number(a.Unit.Format(amount)),
+		})
+	}
+
+	return results, nil
+}
+
+func (a *API) timeline(ctx *gin.Context) (interface{}, error) {
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := a.AddressRecorder.Timeline(ctx.Param("address"), page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var results [][3]interface{}
+	for _, point := range points {
+		results = append(results, [3]interface{}{point.Height, point.Timestamp, json.Number(a.Unit.Format(point.Balance))})
+	}
+
+	return results, nil
+}
+
+//blockStakes returns the block stake rich list
+func (a *API) blockStakes(ctx *gin.Context) (interface{}, error) {
+	over, err := reporter.ParseCurrency(ctx.DefaultQuery("over", "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	page, size, err := pagination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := a.BlockStakeRecorder.Addresses(over, page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var results [][2]interface{}
+	for _, address := range addresses {
+		results = append(results, [2]interface{}{address.Address, json.Number(address.Tokens.String())})
+	}
+
+	return results, nil
+}
+
+func (a *API) blockStakesTotal(ctx *gin.Context) (interface{}, error) {
+	total, err := a.BlockStakeRecorder.Total()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Number(total.String()), nil
+}
+
+func (a *API) addressBlockStakes(ctx *gin.Context) (interface{}, error) {
+	stakes, err := a.BlockStakeRecorder.Get(ctx.Param("address"))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Number(stakes.String()), nil
+}
+
+//metrics serves the spool size, age and dead letters in the prometheus text format
+func (a *API) metrics(ctx *gin.Context) {
+	stats, err := a.Spool.Stats()
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, "%s\n", err)
+		return
+	}
+
+	var b bytes.Buffer
+	for _, metric := range []struct {
+		name  string
+		help  string
+		value interface{}
+	}{
+		{"reporter_spool_batches", "Number of batches of points waiting in the influxdb spool", stats.Batches},
+		{"reporter_spool_points", "Number of points waiting in the influxdb spool", stats.Points},
+		{"reporter_spool_bytes", "Size of the points waiting in the influxdb spool", stats.Bytes},
+		{"reporter_spool_age_seconds", "Age of the oldest batch in the influxdb spool", stats.Age.Seconds()},
+		{"reporter_spool_dead_batches", "Number of spooled batches that influxdb refused", stats.Dead},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", metric.name, metric.help, metric.name, metric.name, metric.value)
+	}
+
+	ctx.Data(http.StatusOK, "text/plain; version=0.0.4", b.Bytes())
 }
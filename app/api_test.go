@@ -0,0 +1,40 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/", jsonAction(func(ctx *gin.Context) (interface{}, error) {
+		page, size, err := pagination(ctx)
+		return []int{page, size}, err
+	}))
+
+	cases := []struct {
+		query    string
+		status   int
+		expected string
+	}{
+		{"", http.StatusOK, "[0,20]\n"},
+		{"?page=2&size=1000", http.StatusOK, "[2,1000]\n"},
+		{"?size=0", http.StatusBadRequest, "\"invalid size '0', expecting 1 to 1000\"\n"},
+		{"?size=-1", http.StatusBadRequest, "\"invalid size '-1', expecting 1 to 1000\"\n"},
+		{"?size=1001", http.StatusBadRequest, "\"invalid size '1001', expecting 1 to 1000\"\n"},
+		{"?size=ten", http.StatusBadRequest, "\"invalid size 'ten', expecting 1 to 1000\"\n"},
+		{"?page=-1", http.StatusBadRequest, "\"invalid page '-1', expecting 0 or more\"\n"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+c.query, nil))
+		if w.Code != c.status || w.Body.String() != c.expected {
+			t.Errorf("%s: got %d %s, expecting %d %s", c.query, w.Code, w.Body, c.status, c.expected)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"fmt"
 
 	logging "github.com/op/go-logging"
 	"github.com/Jumpscale/reporter"
@@ -13,7 +14,6 @@ var (
 
 //Reporter app
 type Reporter struct {
-	Height    int64
 	Explorer  reporter.Explorer
 	Recorders []reporter.Recorder
 
@@ -22,8 +22,6 @@ type Reporter struct {
 
 //Run start collecting and recording statistics data
 func (r *Reporter) Run() error {
-	log.Infof("scanning block chain starting at height: %d", r.Height)
-
 	ctx, cancel := context.WithCancel(context.Background())
 	r.cancel = cancel
 
@@ -36,9 +34,57 @@ func (r *Reporter) Run() error {
 		}
 	}()
 
-	scanner := r.Explorer.Scan(r.Height)
+	if err := r.fork(ctx); err != nil {
+		return err
+	}
+
+	//each recorder resumes from its own cursor, so the scan starts at the lowest one
+	//and blocks already recorded by a recorder are skipped
+	cursors := make([]int64, len(r.Recorders))
+	var height int64 = -1
+	for i, recorder := range r.Recorders {
+		cursor, err := recorder.Cursor()
+		if err != nil {
+			return err
+		}
+
+		cursors[i] = cursor
+		if height < 0 || cursor < height {
+			height = cursor
+		}
+	}
+
+	if height < 0 {
+		height = 0
+	}
+
+	log.Infof("scanning block chain starting at height: %d", height)
+
+	scanner := r.Explorer.Scan(height)
+	last := height - 1
 	for blk := range scanner.Scan(ctx) {
-		for _, recorder := range r.Recorders {
+		if blk.Height <= last {
+			//chain reorganization, revert the orphaned blocks before recording the new branch
+			log.Warningf("rolling back recorders to height: %d", blk.Height-1)
+			for i, recorder := range r.Recorders {
+				if err := recorder.Rollback(blk.Height - 1); err != nil {
+					log.Errorf("error rolling back to height (%d): %s", blk.Height-1, err)
+					return err
+				}
+
+				if cursors[i] > blk.Height {
+					cursors[i] = blk.Height
+				}
+			}
+		}
+
+		last = blk.Height
+		for i, recorder := range r.Recorders {
+			if blk.Height < cursors[i] {
+				continue
+			}
+
+			cursors[i] = blk.Height + 1
 			if err := recorder.Record(blk); err != nil {
 				log.Errorf("error processing block (%d): %s", blk.Height, err)
 				return err
@@ -46,7 +92,57 @@ func (r *Reporter) Run() error {
 		}
 	}
 
-	return scanner.Err()
+	if err := scanner.Err(); err != nil || ctx.Err() != nil {
+		if err != nil {
+			log.Errorf("error scanning block chain: %s", err)
+		}
+
+		return err
+	}
+
+	//the scanner reached the end of an archive, keep the recorders open for the api until stopped
+	log.Info("no more blocks to scan")
+	<-ctx.Done()
+	return nil
+}
+
+//forker is implemented by the recorders that keep the ids of the last recorded blocks
+type forker interface {
+	//Fork returns the height of the last recorded block that is still on the explorer main chain
+	Fork(ctx context.Context, exp reporter.Explorer) (int64, error)
+}
+
+//fork rolls back the recorders to the last recorded block that is still on the chain, in case of a
+//chain reorganization while the reporter was stopped
+func (r *Reporter) fork(ctx context.Context) error {
+	for _, recorder := range r.Recorders {
+		f, ok := recorder.(forker)
+		if !ok {
+			continue
+		}
+
+		cursor, err := recorder.Cursor()
+		if err != nil {
+			return err
+		}
+
+		h, err := f.Fork(ctx, r.Explorer)
+		if err != nil {
+			return fmt.Errorf("check the recorded blocks: %v", err)
+		} else if h >= cursor-1 {
+			continue
+		}
+
+		log.Warningf("chain reorganization while stopped, rolling back recorders to height: %d", h)
+		for _, recorder := range r.Recorders {
+			if err := recorder.Rollback(h); err != nil {
+				log.Errorf("error rolling back to height (%d): %s", h, err)
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 //Stop stops reporter app
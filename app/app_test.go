@@ -0,0 +1,231 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jumpscale/reporter"
+)
+
+//memoryChain serves the explorer api from a list of block ids indexed by height
+type memoryChain struct {
+	m   sync.Mutex
+	ids []string
+	//forks replaces the chain once the block at the given height is served
+	forks map[int64][]string
+	//stale serves the block at the given height once from a branch with an unknown parent
+	stale map[int64]bool
+}
+
+func (c *memoryChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if r.URL.Path == "/explorer" {
+		json.NewEncoder(w).Encode(map[string]int64{"height": int64(len(c.ids) - 1)})
+		return
+	}
+
+	h, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/explorer/blocks/"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if h >= int64(len(c.ids)) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"message": "no block found at height %d"}`, h)
+		return
+	}
+
+	id, parent := c.ids[h], ""
+	if h > 0 {
+		parent = c.ids[h-1]
+	}
+
+	if c.stale[h] {
+		delete(c.stale, h)
+		id, parent = "stale-"+id, "stale-"+parent
+	}
+
+	if ids, ok := c.forks[h]; ok {
+		delete(c.forks, h)
+		c.ids = ids
+	}
+
+	fmt.Fprintf(w, `{"block": {"blockid": "%s", "height": %d, "rawblock": {"parentid": "%s", "timestamp": %d}}}`,
+		id, h, parent, 1500000000+h*120)
+}
+
+//testRecorder keeps the recorded block ids and the rollback heights, and stops the
+//reporter once it records the block last
+type testRecorder struct {
+	cursor    int64
+	last      string
+	stop      func()
+	recorded  []string
+	rollbacks []int64
+}
+
+func (r *testRecorder) Cursor() (int64, error) {
+	return r.cursor, nil
+}
+
+func (r *testRecorder) Record(blk *reporter.Block) error {
+	r.recorded = append(r.recorded, blk.ID)
+	if blk.ID == r.last {
+		r.stop()
+	}
+
+	return nil
+}
+
+func (r *testRecorder) Rollback(h int64) error {
+	r.rollbacks = append(r.rollbacks, h)
+	if r.cursor > h+1 {
+		r.cursor = h + 1
+	}
+
+	return nil
+}
+
+func (r *testRecorder) Close() error {
+	return nil
+}
+
+func TestReporterRollback(t *testing.T) {
+	cases := []struct {
+		name  string
+		chain *memoryChain
+		last  string
+		//cursors of the recorders
+		cursors []int64
+		//recorded and rollbacks are the expected block ids and rollback heights of each recorder
+		recorded  [][]string
+		rollbacks [][]int64
+	}{
+		{
+			name: "reorganization",
+			chain: &memoryChain{
+				ids:   []string{"a0", "a1", "a2", "a3", "a4", "a5"},
+				forks: map[int64][]string{5: {"a0", "a1", "a2", "b3", "b4", "b5", "b6"}},
+			},
+			last:      "b6",
+			cursors:   []int64{0, 4},
+			recorded:  [][]string{{"a0", "a1", "a2", "a3", "a4", "a5", "b3", "b4", "b5", "b6"}, {"a4", "a5", "b3", "b4", "b5", "b6"}},
+			rollbacks: [][]int64{{2}, {2}},
+		},
+		{
+			//the parent of the stale block is still on the main chain, it's fetched again without a rollback
+			name: "transient mismatch",
+			chain: &memoryChain{
+				ids:   []string{"a0", "a1", "a2", "a3", "a4"},
+				stale: map[int64]bool{3: true},
+			},
+			last:      "a4",
+			cursors:   []int64{0, 2},
+			recorded:  [][]string{{"a0", "a1", "a2", "a3", "a4"}, {"a2", "a3", "a4"}},
+			rollbacks: [][]int64{nil, nil},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(c.chain)
+			defer server.Close()
+
+			explorer, err := reporter.NewExplorer(server.URL, reporter.ExplorerOptions{
+				Backoff:      time.Millisecond,
+				PollInterval: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app := &Reporter{Explorer: explorer}
+			var recorders []*testRecorder
+			for _, cursor := range c.cursors {
+				recorder := &testRecorder{cursor: cursor, last: c.last, stop: app.Stop}
+				recorders = append(recorders, recorder)
+				app.Recorders = append(app.Recorders, recorder)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- app.Run()
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("timeout waiting for the last block")
+			}
+
+			for i, recorder := range recorders {
+				if fmt.Sprint(recorder.recorded) != fmt.Sprint(c.recorded[i]) {
+					t.Errorf("recorder (%d) got blocks %v, expecting %v", i, recorder.recorded, c.recorded[i])
+				}
+
+				if fmt.Sprint(recorder.rollbacks) != fmt.Sprint(c.rollbacks[i]) {
+					t.Errorf("recorder (%d) got rollbacks %v, expecting %v", i, recorder.rollbacks, c.rollbacks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReporterForkWhileStopped(t *testing.T) {
+	server := httptest.NewServer(&memoryChain{ids: []string{"a0", "a1", "b2", "b3", "b4", "b5"}})
+	defer server.Close()
+
+	explorer, err := reporter.NewExplorer(server.URL, reporter.ExplorerOptions{
+		Backoff:      time.Millisecond,
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the blocks recorded before the reporter was stopped, the chain switched to another branch after a1
+	ids, err := reporter.NewBlockIDRecorder(filepath.Join(t.TempDir(), "blocks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for h, id := range []string{"a0", "a1", "a2", "a3", "a4"} {
+		if err := ids.Record(&reporter.Block{ID: id, Height: int64(h)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &Reporter{Explorer: explorer}
+	recorder := &testRecorder{cursor: 5, last: "b5", stop: app.Stop}
+	app.Recorders = []reporter.Recorder{recorder, ids}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the last block")
+	}
+
+	if fmt.Sprint(recorder.rollbacks) != "[1]" {
+		t.Errorf("got rollbacks %v, expecting [1]", recorder.rollbacks)
+	}
+
+	if fmt.Sprint(recorder.recorded) != "[b2 b3 b4 b5]" {
+		t.Errorf("got blocks %v, expecting [b2 b3 b4 b5]", recorder.recorded)
+	}
+}
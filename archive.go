@@ -0,0 +1,371 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+//archiveExplorer reads the blocks from an archive, which is a gzip compressed file with one explorer
+//block json per line, ordered by height without gaps. An archive can be made of several gzip members,
+//so it can be extended by appending a new member.
+type archiveExplorer struct {
+	p   string
+	raw bool
+
+	height int64
+	//reader is kept open between calls to GetBlock, so getting the blocks in order reads the archive once
+	reader *archiveReader
+	m      sync.Mutex
+}
+
+//NewArchiveExplorer creates an explorer that reads blocks from the archive at path p, only the Raw option
+//applies to an archive
+func NewArchiveExplorer(p string, opts ExplorerOptions) (Explorer, error) {
+	if _, err := os.Stat(p); err != nil {
+		return nil, err
+	}
+
+	return &archiveExplorer{p: p, raw: opts.Raw, height: -1}, nil
+}
+
+//archiveReader reads the archive blocks one by one
+type archiveReader struct {
+	f   *os.File
+	gz  *gzip.Reader
+	r   *bufio.Reader
+	raw bool
+
+	//first is the height of the first block of the archive
+	first int64
+	//next is the height of the block returned by the next call to next
+	next int64
+	line []byte
+}
+
+func openArchive(p string, raw bool) (*archiveReader, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	reader := &archiveReader{f: f, gz: gz, r: bufio.NewReader(gz), raw: raw}
+	if err := reader.readLine(); err == io.EOF {
+		return reader, nil
+	} else if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	//the archive can start at any height, we need the first block to know it
+	blk, err := reader.decode()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	reader.first = blk.Height
+	reader.next = blk.Height
+	return reader, nil
+}
+
+//readLine reads the next block line, blank lines are skipped
+func (r *archiveReader) readLine() error {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if err == io.EOF && len(line) != 0 {
+			//last line without a new line
+			err = nil
+		}
+
+		r.line = line
+		if err != nil || len(line) != 0 {
+			return err
+		}
+	}
+}
+
+func (r *archiveReader) decode() (*Block, error) {
+	var blk Block
+	if err := json.Unmarshal(r.line, &blk); err != nil {
+		return nil, dataError(err, "archive block (%d)", r.next)
+	}
+
+	if r.raw {
+		blk.Raw = append(json.RawMessage(nil), r.line...)
+	}
+
+	return &blk, nil
+}
+
+//skip moves to the block at height h without decoding the blocks before it
+func (r *archiveReader) skip(h int64) error {
+	if h < r.next || r.line == nil {
+		return noBlockFound(h)
+	}
+
+	for r.next < h {
+		if err := r.readLine(); err == io.EOF {
+			r.line = nil
+			return noBlockFound(h)
+		} else if err != nil {
+			return err
+		}
+
+		r.next++
+	}
+
+	return nil
+}
+
+//Next returns the next block of the archive, or a no block found error at the end of the archive
+func (r *archiveReader) Next() (*Block, error) {
+	if r.line == nil {
+		return nil, noBlockFound(r.next)
+	}
+
+	blk, err := r.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	if blk.Height != r.next {
+		return nil, fmt.Errorf("archive block (%d) has height %d, expecting blocks ordered by height without gaps", r.next, blk.Height)
+	}
+
+	r.next++
+	if err := r.readLine(); err == io.EOF {
+		r.line = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return blk, nil
+}
+
+func (r *archiveReader) Close() error {
+	r.gz.Close()
+	return r.f.Close()
+}
+
+//GetBlock returns the block at height h, the archive is only read again from the start to get a block
+//before the last one returned
+func (e *archiveExplorer) GetBlock(ctx context.Context, h int64) (*Block, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.reader != nil && h < e.reader.next && h >= e.reader.first {
+		e.reader.Close()
+		e.reader = nil
+	}
+
+	if e.reader == nil {
+		reader, err := openArchive(e.p, e.raw)
+		if err != nil {
+			return nil, err
+		}
+
+		e.reader = reader
+	}
+
+	if h < e.reader.first {
+		return nil, noBlockFound(h)
+	}
+
+	if err := e.reader.skip(h); err != nil {
+		return nil, err
+	}
+
+	return e.reader.Next()
+}
+
+//ChainHeight returns the height of the last block of the archive. The archive is read once and the height
+//is cached, since the archive is expected to be complete: blocks appended to it afterwards, like by a dump
+//still running, are not returned by this explorer.
+func (e *archiveExplorer) ChainHeight(ctx context.Context) (int64, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.height >= 0 {
+		return e.height, nil
+	}
+
+	reader, err := openArchive(e.p, false)
+	if err != nil {
+		return 0, err
+	}
+
+	defer reader.Close()
+
+	if reader.line == nil {
+		return 0, fmt.Errorf("archive '%s' is empty", e.p)
+	}
+
+	for {
+		if err := reader.readLine(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		reader.next++
+	}
+
+	e.height = reader.next
+	return e.height, nil
+}
+
+func (e *archiveExplorer) Scan(head int64) Scanner {
+	return &archiveScanner{p: e.p, raw: e.raw, head: head}
+}
+
+//archiveScanner returns the archive blocks starting at head, and stops at the end of the archive
+type archiveScanner struct {
+	p    string
+	raw  bool
+	head int64
+	err  error
+}
+
+func (s *archiveScanner) Err() error {
+	return s.err
+}
+
+func (s *archiveScanner) Scan(ctx context.Context) <-chan *Block {
+	ch := make(chan *Block)
+
+	go func() {
+		defer close(ch)
+
+		reader, err := openArchive(s.p, s.raw)
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		defer reader.Close()
+
+		//we need the parent of the first block to check the blocks are chained
+		var parent *Block
+		if s.head > reader.first {
+			err = reader.skip(s.head - 1)
+			if err == nil {
+				parent, err = reader.Next()
+			}
+		} else {
+			err = reader.skip(s.head)
+		}
+
+		if eerr, ok := err.(ExplorerError); ok && eerr.NoBlockFound() {
+			log.Infof("archive '%s' has no blocks at height %d", s.p, s.head)
+			return
+		} else if err != nil {
+			s.err = err
+			return
+		}
+
+		for {
+			blk, err := reader.Next()
+			if eerr, ok := err.(ExplorerError); ok && eerr.NoBlockFound() {
+				log.Infof("reached the end of archive '%s' at height %d", s.p, s.head)
+				return
+			} else if err != nil {
+				s.err = err
+				return
+			}
+
+			if parent != nil && parent.ID != blk.RawBlock.ParentID {
+				s.err = fmt.Errorf("archive block (%d) parent '%s' doesn't match block id '%s'", blk.Height, blk.RawBlock.ParentID, parent.ID)
+				return
+			}
+
+			select {
+			case ch <- blk:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+
+			parent = blk
+			s.head++
+		}
+	}()
+
+	return ch
+}
+
+//ArchiveWriter appends blocks to an archive
+type ArchiveWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+//OpenArchiveWriter opens the archive at path p for appending, and returns the height of the
+//next block to write. A new archive starts at height 0.
+func OpenArchiveWriter(p string) (*ArchiveWriter, int64, error) {
+	var next int64
+	if info, err := os.Stat(p); err == nil && info.Size() > 0 {
+		exp, err := NewArchiveExplorer(p, ExplorerOptions{})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		height, err := exp.ChainHeight(context.Background())
+		if err != nil {
+			return nil, 0, fmt.Errorf("archive '%s' can't be extended: %v", p, err)
+		}
+
+		next = height + 1
+	}
+
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &ArchiveWriter{f: f, gz: gzip.NewWriter(f)}, next, nil
+}
+
+//Write appends a block to the archive
+func (w *ArchiveWriter) Write(blk *Block) error {
+	raw := blk.Raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(blk); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	//the archive has a block per line
+	if err := json.Compact(&buf, raw); err != nil {
+		return err
+	}
+
+	buf.WriteByte('\n')
+	_, err := w.gz.Write(buf.Bytes())
+	return err
+}
+
+//Close completes the archive gzip member and closes the file
+func (w *ArchiveWriter) Close() error {
+	if err := w.gz.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+
+	return w.f.Close()
+}
@@ -0,0 +1,173 @@
+package reporter
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//archiveBlock returns an empty block at height h, chained to the block at height h-1
+func archiveBlock(h int64) *Block {
+	blk := &Block{ID: fmt.Sprintf("block-%d", h), Height: h}
+	blk.RawBlock.ParentID = fmt.Sprintf("block-%d", h-1)
+	blk.RawBlock.Timestamp = 1500000000 + h*120
+	return blk
+}
+
+//scanArchive returns the ids of the blocks of the archive scanned from head
+func scanArchive(t *testing.T, exp Explorer, head int64) []string {
+	scanner := exp.Scan(head)
+	var ids []string
+	for blk := range scanner.Scan(context.Background()) {
+		ids = append(ids, blk.ID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "blocks.gz")
+
+	//the second dump appends a new gzip member to the archive
+	for _, dump := range []struct{ from, to int64 }{{0, 3}, {3, 5}} {
+		writer, next, err := OpenArchiveWriter(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next != dump.from {
+			t.Errorf("got next height %d, expecting %d", next, dump.from)
+		}
+
+		for h := next; h < dump.to; h++ {
+			if err := writer.Write(archiveBlock(h)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exp, err := NewArchiveExplorer(p, ExplorerOptions{Raw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if height, err := exp.ChainHeight(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if height != 4 {
+		t.Errorf("got chain height %d, expecting 4", height)
+	}
+
+	//getting a block before the last one returned reads the archive again
+	for _, h := range []int64{3, 1, 4} {
+		blk, err := exp.GetBlock(context.Background(), h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if blk.ID != archiveBlock(h).ID || len(blk.Raw) == 0 {
+			t.Errorf("got block '%s' with %d raw bytes at height %d, expecting '%s' with its json", blk.ID, len(blk.Raw), h, archiveBlock(h).ID)
+		}
+	}
+
+	if _, err := exp.GetBlock(context.Background(), 5); err == nil {
+		t.Error("expecting a no block found error after the last block")
+	} else if eerr, ok := err.(ExplorerError); !ok || !eerr.NoBlockFound() {
+		t.Errorf("got error %v, expecting a no block found error", err)
+	}
+
+	if ids := scanArchive(t, exp, 0); strings.Join(ids, " ") != "block-0 block-1 block-2 block-3 block-4" {
+		t.Errorf("got blocks %v scanned from height 0", ids)
+	}
+
+	if ids := scanArchive(t, exp, 3); strings.Join(ids, " ") != "block-3 block-4" {
+		t.Errorf("got blocks %v scanned from height 3", ids)
+	}
+
+	if ids := scanArchive(t, exp, 5); len(ids) != 0 {
+		t.Errorf("got blocks %v scanned after the last block", ids)
+	}
+}
+
+//writeArchive writes the lines as an archive of a single gzip member
+func writeArchive(t *testing.T, lines string) string {
+	p := filepath.Join(t.TempDir(), "blocks.gz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(lines)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestArchiveLines(t *testing.T) {
+	line := func(h int64, parent string) string {
+		return fmt.Sprintf(`{"blockid": "block-%d", "height": %d, "rawblock": {"parentid": "%s"}}`, h, h, parent)
+	}
+
+	cases := []struct {
+		name   string
+		lines  string
+		height int64
+		ids    string
+		err    string
+	}{
+		{"no final new line", line(7, "block-6") + "\n" + line(8, "block-7"), 8, "block-7 block-8", ""},
+		{"blank lines", line(7, "block-6") + "\n\n" + line(8, "block-7") + "\n\n", 8, "block-7 block-8", ""},
+		{"gap", line(7, "block-6") + "\n" + line(9, "block-8") + "\n", 8, "block-7", "archive block (8) has height 9"},
+		{"parent mismatch", line(7, "block-6") + "\n" + line(8, "block-0") + "\n", 8, "block-7", "archive block (8) parent 'block-0'"},
+	}
+
+	//an archive can start at any height
+	for _, c := range cases {
+		exp, err := NewArchiveExplorer(writeArchive(t, c.lines), ExplorerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if height, err := exp.ChainHeight(context.Background()); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if height != c.height {
+			t.Errorf("%s: got chain height %d, expecting %d", c.name, height, c.height)
+		}
+
+		scanner := exp.Scan(7)
+		var ids []string
+		for blk := range scanner.Scan(context.Background()) {
+			ids = append(ids, blk.ID)
+		}
+
+		if strings.Join(ids, " ") != c.ids {
+			t.Errorf("%s: got blocks %v, expecting %s", c.name, ids, c.ids)
+		}
+
+		err = scanner.Err()
+		if len(c.err) == 0 && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if len(c.err) != 0 && (err == nil || !strings.HasPrefix(err.Error(), c.err)) {
+			t.Errorf("%s: got error %v, expecting %q", c.name, err, c.err)
+		}
+	}
+}
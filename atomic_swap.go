@@ -0,0 +1,172 @@
+package reporter
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+//SwapStatus status of an atomic swap contract
+type SwapStatus string
+
+const (
+	//SwapOpen the contract fund was not spent yet
+	SwapOpen SwapStatus = "open"
+	//SwapClaimed the receiver claimed the fund using the secret
+	SwapClaimed SwapStatus = "claimed"
+	//SwapRefunded the sender took back the fund after the time lock
+	SwapRefunded SwapStatus = "refunded"
+)
+
+//AtomicSwap is an atomic swap contract, it's identified by the id of the output that holds the fund
+type AtomicSwap struct {
+	ID           string
+	Address      string
+	Sender       string
+	Receiver     string
+	HashedSecret string
+	TimeLock     int64
+	Value        Currency
+	Height       int64
+	Status       SwapStatus
+	//Spent is the height of the block that claimed or refunded the contract
+	Spent  int64
+	Secret string
+}
+
+//openSwaps keeps track of the atomic swap contracts created by the transaction outputs
+func (r *AddressRecorder) openSwaps(tx querier, blk *Block, txn *Transaction) error {
+	for i, output := range txn.RawTransaction.Data.CoinOutputs {
+		data, ok := output.Condition.Value().(AtomicSwapConditionData)
+		if !ok {
+			continue
+		}
+
+		if i >= len(txn.CoinOutputIDs) {
+			return fmt.Errorf("at index (%d): missing output id of atomic swap", i)
+		}
+
+		var hash string
+		if i < len(txn.CoinOutputUnlockHashes) {
+			hash = txn.CoinOutputUnlockHashes[i]
+		}
+
+		address, err := conditionOwner(&output.Condition, hash)
+		if err != nil {
+			return fmt.Errorf("at index (%d): %v", i, err)
+		}
+
+		_, err = tx.Exec(
+			`insert into swap (id, address, sender, receiver, hashedsecret, timelock, value, height, status)
+			values (?, ?, ?, ?, ?, ?, ?, ?, ?) on conflict (id) do update set
+			address = excluded.address, sender = excluded.sender, receiver = excluded.receiver,
+			hashedsecret = excluded.hashedsecret, timelock = excluded.timelock, value = excluded.value,
+			height = excluded.height, status = excluded.status, spent = null, secret = '';`,
+			txn.CoinOutputIDs[i], address, data.Sender, data.Receiver, data.HashedSecret,
+			data.TimeLock, output.Value.String(), blk.Height, SwapOpen,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//spendSwaps marks the atomic swap contracts spent by the transaction inputs as claimed or refunded
+func (r *AddressRecorder) spendSwaps(tx querier, blk *Block, txn *Transaction) error {
+	for i, input := range txn.RawTransaction.Data.CoinInputs {
+		if i >= len(txn.CoinInputOutputs) || txn.CoinInputOutputs[i].Condition.Type != AtomicSwapCondition {
+			continue
+		}
+
+		//only the receiver can provide the secret, the sender refunds without it
+		status := SwapRefunded
+		var secret string
+		if input.Fulfillment.Type == AtomicSwapFulfillment {
+			data, err := input.Fulfillment.AtomicSwapData()
+			if err != nil {
+				return fmt.Errorf("at index (%d): %v", i, err)
+			}
+
+			secret = data.Secret
+		}
+
+		if len(secret) != 0 {
+			status = SwapClaimed
+		}
+
+		result, err := tx.Exec(
+			"update swap set status = ?, spent = ?, secret = ? where id = ?;",
+			status, blk.Height, secret, input.ParentID,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		//the contract is created by an earlier output, so it is missing only if that output was not recorded
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			log.Warningf("block %d: input (%d) of transaction %s spends unknown atomic swap contract %s",
+				blk.Height, i, txn.ID, input.ParentID)
+		}
+	}
+
+	return nil
+}
+
+func (r *AddressRecorder) swaps(query string, args ...interface{}) ([]AtomicSwap, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var swaps []AtomicSwap
+	for rows.Next() {
+		var swap AtomicSwap
+		var value string
+		var spent sql.NullInt64
+		if err := rows.Scan(
+			&swap.ID, &swap.Address, &swap.Sender, &swap.Receiver, &swap.HashedSecret,
+			&swap.TimeLock, &value, &swap.Height, &swap.Status, &spent, &swap.Secret,
+		); err != nil {
+			return nil, err
+		}
+
+		if swap.Value, err = ParseCurrency(value); err != nil {
+			return nil, err
+		}
+
+		swap.Spent = spent.Int64
+		swaps = append(swaps, swap)
+	}
+
+	return swaps, rows.Err()
+}
+
+const swapColumns = "id, address, sender, receiver, hashedsecret, timelock, value, height, status, spent, secret"
+
+//OpenSwaps returns the atomic swap contracts that are not claimed or refunded yet
+func (r *AddressRecorder) OpenSwaps(page, size int) ([]AtomicSwap, error) {
+	return r.swaps(
+		"select "+swapColumns+" from swap where status = ? order by height desc, id limit ? offset ?;",
+		SwapOpen, size, page*size,
+	)
+}
+
+//Swaps returns the atomic swap contracts where the given address is the sender or the receiver
+func (r *AddressRecorder) Swaps(address string, page, size int) ([]AtomicSwap, error) {
+	return r.swaps(
+		"select "+swapColumns+" from swap where sender = ? or receiver = ? order by height desc, id limit ? offset ?;",
+		address, address, size, page*size,
+	)
+}
+
+//SwapVolume returns the amount of tokens locked in open atomic swap contracts
+func (r *AddressRecorder) SwapVolume() (Currency, error) {
+	return r.sum(r.db, "select value from swap where status = ?;", SwapOpen)
+}
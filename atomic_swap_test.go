@@ -0,0 +1,147 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//swapBlock returns a block with a transaction that creates an atomic swap contract of output id, and
+//optionally a transaction that spends the contract created by spent with the given secret
+func swapBlock(t *testing.T, h int64, id string, spent string, secret string) *Block {
+	contract := fmt.Sprintf(`{"type": 2, "data": {"sender": "%s", "receiver": "%s", "hashedsecret": "%s", "timelock": 1600000000}}`,
+		testUnlockHash1, testUnlockHash2, "ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00")
+
+	var txns []string
+	if len(id) != 0 {
+		txns = append(txns, fmt.Sprintf(`{
+			"id": "create-%d", "height": %d,
+			"rawtransaction": {"version": 1, "data": {"coinoutputs": [{"value": "1000", "condition": %s}]}},
+			"coinoutputids": ["%s"]
+		}`, h, h, contract, id))
+	}
+
+	if len(spent) != 0 {
+		txns = append(txns, fmt.Sprintf(`{
+			"id": "spend-%d", "height": %d,
+			"rawtransaction": {"version": 1, "data": {
+				"coininputs": [{"parentid": "%s", "fulfillment": {"type": 2, "data": {"publickey": "ed25519:00", "signature": "00", "secret": "%s"}}}],
+				"coinoutputs": [{"value": "1000", "condition": {"type": 1, "data": {"unlockhash": "%s"}}}]
+			}},
+			"coinoutputids": ["out-%d"],
+			"coininputoutputs": [{"value": "1000", "condition": %s}]
+		}`, h, h, spent, secret, testUnlockHash2, h, contract))
+	}
+
+	text := fmt.Sprintf(`{"blockid": "block-%d", "height": %d, "rawblock": {"timestamp": %d}, "transactions": [`,
+		h, h, 1500000000+h*120)
+	for i, txn := range txns {
+		if i > 0 {
+			text += ","
+		}
+
+		text += txn
+	}
+
+	var blk Block
+	if err := json.Unmarshal([]byte(text+"]}"), &blk); err != nil {
+		t.Fatal(err)
+	}
+
+	return &blk
+}
+
+func TestSwapClaimAndRefund(t *testing.T) {
+	recorder, err := NewAddressRecorder(filepath.Join(t.TempDir(), "addresses.db"), 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	blocks := []*Block{
+		swapBlock(t, 0, "claimed", "", ""),
+		swapBlock(t, 1, "refunded", "", ""),
+		swapBlock(t, 2, "open", "claimed", "aa"),
+		swapBlock(t, 3, "", "refunded", ""),
+		//spending an unknown contract is logged, not recorded
+		swapBlock(t, 4, "", "unknown", "bb"),
+	}
+
+	for _, blk := range blocks {
+		if err := recorder.Record(blk); err != nil {
+			t.Fatalf("block %d: %v", blk.Height, err)
+		}
+	}
+
+	address, err := AtomicSwapConditionData{
+		Sender:       testUnlockHash1,
+		Receiver:     testUnlockHash2,
+		HashedSecret: "ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00",
+		TimeLock:     1600000000,
+	}.UnlockHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swaps, err := recorder.Swaps(testUnlockHash1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct {
+		status SwapStatus
+		spent  int64
+		secret string
+	}{
+		"open":     {SwapOpen, 0, ""},
+		"claimed":  {SwapClaimed, 2, "aa"},
+		"refunded": {SwapRefunded, 3, ""},
+	}
+
+	if len(swaps) != len(expected) {
+		t.Fatalf("got %d swaps, expecting %d", len(swaps), len(expected))
+	}
+
+	for _, swap := range swaps {
+		e, ok := expected[swap.ID]
+		if !ok {
+			t.Errorf("unexpected swap %s", swap.ID)
+			continue
+		}
+
+		if swap.Status != e.status || swap.Spent != e.spent || swap.Secret != e.secret {
+			t.Errorf("swap %s: got (%s, %d, %q), expecting (%s, %d, %q)",
+				swap.ID, swap.Status, swap.Spent, swap.Secret, e.status, e.spent, e.secret)
+		}
+
+		if swap.Address != address {
+			t.Errorf("swap %s: got address %s, expecting %s", swap.ID, swap.Address, address)
+		}
+	}
+
+	volume, err := recorder.SwapVolume()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if volume.String() != "1000" {
+		t.Errorf("got swap volume %s, expecting 1000", volume)
+	}
+
+	//rolling back the claim opens the contract again
+	if err := recorder.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := recorder.OpenSwaps(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(open) != 2 {
+		t.Errorf("got %d open swaps after rollback, expecting 2", len(open))
+	}
+}
@@ -0,0 +1,161 @@
+package reporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+//BlockIDRecorder keeps the ids of the last MaxReorgDepth recorded blocks with its cursor, so a chain
+//reorganization that happened while the reporter was stopped is detected on start (see Fork). It's
+//the last recorder, so the ids are only kept once the other recorders recorded their blocks.
+type BlockIDRecorder struct {
+	db *sql.DB
+}
+
+//NewBlockIDRecorder creates a new block id recorder
+func NewBlockIDRecorder(p string) (*BlockIDRecorder, error) {
+	db, err := openSQLite(p)
+	if err != nil {
+		return nil, err
+	}
+	exec := `
+	create table if not exists block_id (
+		height integer not null primary key,
+		id text not null
+	);
+	` + cursorSchema
+	_, err = db.Exec(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockIDRecorder{db: db}, nil
+}
+
+//Cursor returns the height of the next block to record
+func (r *BlockIDRecorder) Cursor() (int64, error) {
+	return getCursor(r.db)
+}
+
+//Record keeps the block id, the ids of the blocks that can't be rolled back anymore are removed
+func (r *BlockIDRecorder) Record(blk *Block) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(tx, blk); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setCursor(tx, blk.Height+1); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BlockIDRecorder) record(tx *sql.Tx, blk *Block) error {
+	if _, err := tx.Exec("insert or replace into block_id (height, id) values (?, ?);", blk.Height, blk.ID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("delete from block_id where height <= ?;", blk.Height-MaxReorgDepth)
+	return err
+}
+
+//Rollback removes the ids of the blocks with height above h
+func (r *BlockIDRecorder) Rollback(h int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("delete from block_id where height > ?;", h); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	cursor, err := getCursor(tx)
+	if err == nil && cursor > h+1 {
+		err = setCursor(tx, h+1)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//Fork returns the height of the last recorded block that is still on the main chain of the explorer,
+//walking back from the last recorded block. It's the height before the cursor if no block id is kept.
+func (r *BlockIDRecorder) Fork(ctx context.Context, exp Explorer) (int64, error) {
+	cursor, err := getCursor(r.db)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := r.db.Query("select height, id from block_id order by height desc;")
+	if err != nil {
+		return 0, err
+	}
+
+	type blockID struct {
+		height int64
+		id     string
+	}
+
+	var ids []blockID
+	for rows.Next() {
+		var id blockID
+		if err := rows.Scan(&id.height, &id.id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return cursor - 1, nil
+	}
+
+	found := false
+	for _, id := range ids {
+		blk, err := exp.GetBlock(ctx, id.height)
+		if eerr, ok := err.(ExplorerError); ok && eerr.NoBlockFound() {
+			//the new branch is still shorter than the orphaned one
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		if blk.ID == id.id {
+			return id.height, nil
+		}
+
+		found = true
+	}
+
+	if !found {
+		//the explorer chain didn't reach the recorded blocks yet, the scanner waits for it
+		return cursor - 1, nil
+	}
+
+	return 0, fmt.Errorf("none of the last %d recorded blocks is on the chain anymore, the chain reorganization is too deep", len(ids))
+}
+
+//Close the recorder
+func (r *BlockIDRecorder) Close() error {
+	return r.db.Close()
+}
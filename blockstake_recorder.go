@@ -0,0 +1,346 @@
+package reporter
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+//BlockStakeRecorder keeps track of the block stakes balances of the addresses, block stakes are
+//used to create blocks and are counted as a whole, they have no unit
+type BlockStakeRecorder struct {
+	db *sql.DB
+}
+
+//NewBlockStakeRecorder creates a new block stake recorder
+func NewBlockStakeRecorder(p string) (*BlockStakeRecorder, error) {
+	db, err := openSQLite(p)
+	if err != nil {
+		return nil, err
+	}
+	exec := `
+	create table if not exists blockstake (
+		address text not null primary key,
+		value text not null
+	);
+
+	create index if not exists blockstake_value_index on blockstake (value);
+
+	create table if not exists blockstake_timeline (
+		address text not null,
+		height integer not null,
+		value text not null,
+		primary key (address, height)
+	);
+
+	create index if not exists blockstake_timeline_height_index on blockstake_timeline (height);
+
+	create table if not exists blockstake_total (
+		id integer not null primary key,
+		total text not null
+	);
+	` + cursorSchema
+	_, err = db.Exec(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockStakeRecorder{db: db}, nil
+}
+
+//Cursor returns the height of the next block to record
+func (r *BlockStakeRecorder) Cursor() (int64, error) {
+	return getCursor(r.db)
+}
+
+//Record record a block on the block stake recorder, the block is applied atomically with the cursor
+func (r *BlockStakeRecorder) Record(blk *Block) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(tx, blk); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setCursor(tx, blk.Height+1); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//apply adds (or subtracts with op opSub) the block stakes of the input/outputs to their owners, hashes
+//are the unlock hashes of the input/outputs as computed by the explorer
+func (r *BlockStakeRecorder) apply(addresses Addresses, inouts []InputOutput, hashes []string, op int) error {
+	for i, inout := range inouts {
+		owner := inout.UnlockHash
+		if len(owner) == 0 {
+			var hash string
+			if i < len(hashes) {
+				hash = hashes[i]
+			}
+
+			var err error
+			if owner, err = conditionOwner(&inout.Condition, hash); err != nil {
+				return fmt.Errorf("at index (%d): %s", i, err)
+			}
+		}
+
+		if len(owner) == 0 {
+			continue
+		}
+
+		if op == opAdd {
+			addresses[owner] = addresses[owner].Add(inout.Value)
+		} else {
+			addresses[owner] = addresses[owner].Sub(inout.Value)
+		}
+	}
+
+	return nil
+}
+
+func (r *BlockStakeRecorder) record(tx *sql.Tx, blk *Block) error {
+	//the total is read before any change of the block, so it's computed without them the first time
+	total, err := r.total(tx)
+	if err != nil {
+		return fmt.Errorf("total: %v", err)
+	}
+
+	addresses := Addresses{}
+	for i, txn := range blk.Transactions {
+		data := &txn.RawTransaction.Data
+		if len(data.BlockStakeInputs) != len(txn.BlockStakeInputOutputs) {
+			return fmt.Errorf("transaction (%d): has %d block stake inputs but %d spent outputs", i, len(data.BlockStakeInputs), len(txn.BlockStakeInputOutputs))
+		}
+
+		if err := r.apply(addresses, data.BlockStakeOutputs, txn.BlockStakeOutputUnlockHashes, opAdd); err != nil {
+			return fmt.Errorf("transaction (%d): block stake outputs: %v", i, err)
+		}
+
+		if err := r.apply(addresses, txn.BlockStakeInputOutputs, nil, opSub); err != nil {
+			return fmt.Errorf("transaction (%d): block stake inputs: %v", i, err)
+		}
+	}
+
+	for address, delta := range addresses {
+		if delta.Sign() == 0 {
+			continue
+		}
+
+		current, err := r.get(tx, address)
+		if err != nil {
+			return err
+		}
+
+		balance := current.Add(delta)
+		if err := r.set(tx, address, balance); err != nil {
+			return err
+		}
+
+		total = total.Add(delta)
+
+		_, err = tx.Exec(
+			"insert or replace into blockstake_timeline (address, height, value) values (?, ?, ?);",
+			address, blk.Height, balance.sortable(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.setTotal(tx, total)
+}
+
+func (r *BlockStakeRecorder) get(q querier, address string) (Currency, error) {
+	row := q.QueryRow("select value from blockstake where address = ?;", address)
+	var value string
+	if err := row.Scan(&value); err == sql.ErrNoRows {
+		return Currency{}, nil
+	} else if err != nil {
+		return Currency{}, err
+	}
+
+	return parseSortable(value)
+}
+
+func (r *BlockStakeRecorder) set(q querier, address string, value Currency) error {
+	_, err := q.Exec("insert or replace into blockstake (address, value) values (?, ?);", address, value.sortable())
+	return err
+}
+
+//Rollback reverts the block stake changes of all blocks with height above h
+func (r *BlockStakeRecorder) Rollback(h int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.rollback(tx, h); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BlockStakeRecorder) rollback(tx *sql.Tx, h int64) error {
+	rows, err := tx.Query("select distinct address from blockstake_timeline where height > ?;", h)
+	if err != nil {
+		return err
+	}
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			rows.Close()
+			return err
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	total, err := r.total(tx)
+	if err != nil {
+		return fmt.Errorf("total: %v", err)
+	}
+
+	//restore the balances from the last timeline point that is kept
+	for _, address := range addresses {
+		current, err := r.get(tx, address)
+		if err != nil {
+			return err
+		}
+
+		var value string
+		row := tx.QueryRow(
+			"select value from blockstake_timeline where address = ? and height <= ? order by height desc limit 1;",
+			address, h,
+		)
+
+		balance := Currency{}
+		if err := row.Scan(&value); err == nil {
+			if balance, err = parseSortable(value); err != nil {
+				return err
+			}
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+
+		if err := r.set(tx, address, balance); err != nil {
+			return err
+		}
+
+		total = total.Add(balance.Sub(current))
+	}
+
+	if err := r.setTotal(tx, total); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("delete from blockstake_timeline where height > ?;", h); err != nil {
+		return err
+	}
+
+	cursor, err := getCursor(tx)
+	if err != nil || cursor <= h+1 {
+		return err
+	}
+
+	return setCursor(tx, h+1)
+}
+
+//Close the recorder, any calls to record after that will fail
+func (r *BlockStakeRecorder) Close() error {
+	return r.db.Close()
+}
+
+//Get returns the block stakes of this address
+func (r *BlockStakeRecorder) Get(address string) (Currency, error) {
+	return r.get(r.db, address)
+}
+
+//Addresses returns the addresses that have at least over block stakes, sorted by their block stakes
+func (r *BlockStakeRecorder) Addresses(over Currency, page, size int) ([]Address, error) {
+	rows, err := r.db.Query(
+		"select address, value from blockstake where value >= ? and value > ? order by value desc limit ? offset ?;",
+		over.sortable(), Currency{}.sortable(), size, page*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var addresses []Address
+	for rows.Next() {
+		var address Address
+		var value string
+		if err := rows.Scan(&address.Address, &value); err != nil {
+			return nil, err
+		}
+
+		if address.Tokens, err = parseSortable(value); err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses, rows.Err()
+}
+
+//Total returns the block stakes of all addresses
+func (r *BlockStakeRecorder) Total() (Currency, error) {
+	return r.total(r.db)
+}
+
+//total returns the block stakes of all addresses. It's kept up to date by the recorded blocks, and
+//computed from all balances if the recorder has no total yet.
+func (r *BlockStakeRecorder) total(q querier) (Currency, error) {
+	var value string
+	row := q.QueryRow("select total from blockstake_total where id = 0;")
+	if err := row.Scan(&value); err == nil {
+		return ParseCurrency(value)
+	} else if err != sql.ErrNoRows {
+		return Currency{}, err
+	}
+
+	rows, err := q.Query("select value from blockstake;")
+	if err != nil {
+		return Currency{}, err
+	}
+
+	defer rows.Close()
+
+	var total Currency
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return total, err
+		}
+
+		amount, err := parseSortable(value)
+		if err != nil {
+			return total, err
+		}
+
+		total = total.Add(amount)
+	}
+
+	return total, rows.Err()
+}
+
+func (r *BlockStakeRecorder) setTotal(q querier, total Currency) error {
+	_, err := q.Exec("insert or replace into blockstake_total (id, total) values (0, ?);", total.String())
+	return err
+}
@@ -0,0 +1,185 @@
+package reporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	//DefaultChain is the chain profile used when none is given
+	DefaultChain = "tfchain-mainnet"
+	//DefaultPrecision of the chain profiles that don't set it
+	DefaultPrecision = 9
+)
+
+//builtinChains are the known chain profiles, in the same format as the chains config file. The
+//tfchain mainnet keeps the database and file names of the reporter versions that only supported it. The
+//other chains are defined in a chains config, with their genesis and block reward.
+const builtinChains = `
+tfchain-mainnet:
+  genesis: "695099000"
+  blockreward: "1"
+  precision: 9
+  currency: TFT
+  database: rivine
+  storage: rivine.db
+  prefix: ""
+`
+
+//chainProfile is a chain profile as written in the config file, amounts are in coins
+type chainProfile struct {
+	Genesis     string  `yaml:"genesis"`
+	BlockReward string  `yaml:"blockreward"`
+	Precision   *uint   `yaml:"precision"`
+	Currency    string  `yaml:"currency"`
+	Database    string  `yaml:"database"`
+	Storage     string  `yaml:"storage"`
+	Prefix      *string `yaml:"prefix"`
+}
+
+//Chain is the profile of a rivine block chain
+type Chain struct {
+	Name string
+	//Genesis is the total value of the genesis coin outputs, zero if unknown
+	Genesis Currency
+	//BlockReward is the value created by each block for its creator, zero if unknown
+	BlockReward Currency
+	//Unit of the chain currency
+	Unit Unit
+	//Currency is the currency name
+	Currency string
+	//Database is the influx database name
+	Database string
+	//Storage is the file name of the address balances database under the home directory
+	Storage string
+	//Prefix of the names of the other databases under the home directory (see File)
+	Prefix string
+	//Strict fails to record a block that doesn't match the profile genesis or block reward, instead
+	//of logging a warning (see Check)
+	Strict bool
+}
+
+func (p *chainProfile) chain(name string) (*Chain, error) {
+	chain := Chain{
+		Name:     name,
+		Unit:     DefaultPrecision,
+		Currency: p.Currency,
+		Database: p.Database,
+		Storage:  p.Storage,
+	}
+
+	if p.Precision != nil {
+		chain.Unit = Unit(*p.Precision)
+	}
+
+	for _, amount := range []struct {
+		field string
+		value string
+		c     *Currency
+	}{{"genesis", p.Genesis, &chain.Genesis}, {"blockreward", p.BlockReward, &chain.BlockReward}} {
+		if len(amount.value) == 0 {
+			continue
+		}
+
+		var err error
+		if *amount.c, err = chain.Unit.Parse(amount.value); err != nil {
+			return nil, fmt.Errorf("chain '%s' %s: %v", name, amount.field, err)
+		}
+	}
+
+	if len(chain.Database) == 0 {
+		chain.Database = strings.Replace(name, "-", "_", -1)
+	}
+
+	if len(chain.Storage) == 0 {
+		chain.Storage = name + ".db"
+	}
+
+	chain.Prefix = strings.TrimSuffix(chain.Storage, ".db") + "-"
+	if p.Prefix != nil {
+		chain.Prefix = *p.Prefix
+	}
+
+	return &chain, nil
+}
+
+//File returns the file name of the database with the given name under the home directory, so the
+//databases of several chains can be in the same home directory
+func (c *Chain) File(name string) string {
+	return c.Prefix + name + ".db"
+}
+
+func parseChains(data []byte, chains map[string]chainProfile) error {
+	return yaml.Unmarshal(data, &chains)
+}
+
+//LoadChain returns the chain profile with the given name, from the built in profiles and the
+//profiles of the config file at path p if given. A profile in the config file replaces the built in
+//profile with the same name.
+func LoadChain(name string, p string) (*Chain, error) {
+	chains := make(map[string]chainProfile)
+	if err := parseChains([]byte(builtinChains), chains); err != nil {
+		return nil, err
+	}
+
+	if len(p) != 0 {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := parseChains(data, chains); err != nil {
+			return nil, fmt.Errorf("chains config '%s': %v", p, err)
+		}
+	}
+
+	profile, ok := chains[name]
+	if !ok {
+		var names []string
+		for name := range chains {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown chain '%s', expecting one of (%s)", name, strings.Join(names, ", "))
+	}
+
+	return profile.chain(name)
+}
+
+//Complete returns an error if the profile doesn't have the genesis or the block reward, the block
+//issuance can't be checked against an incomplete profile
+func (c *Chain) Complete() error {
+	var missing []string
+	if c.Genesis.Sign() == 0 {
+		missing = append(missing, "genesis")
+	}
+
+	if c.BlockReward.Sign() == 0 {
+		missing = append(missing, "blockreward")
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("chain '%s' profile has no %s", c.Name, strings.Join(missing, " and "))
+	}
+
+	return nil
+}
+
+//Check returns an error if the block issuance doesn't match the chain profile, the genesis
+//is checked at height 0 and the block reward at height 1
+func (c *Chain) Check(height int64, reward, minted Currency) error {
+	if height == 0 && c.Genesis.Sign() != 0 && minted.Cmp(c.Genesis) != 0 {
+		return fmt.Errorf("chain '%s' genesis is %s but the genesis block has %s", c.Name, c.Unit.Format(c.Genesis), c.Unit.Format(minted))
+	}
+
+	if height == 1 && c.BlockReward.Sign() != 0 && reward.Cmp(c.BlockReward) != 0 {
+		return fmt.Errorf("chain '%s' block reward is %s but block 1 has %s", c.Name, c.Unit.Format(c.BlockReward), c.Unit.Format(reward))
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package reporter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinChains(t *testing.T) {
+	chains := make(map[string]chainProfile)
+	if err := parseChains([]byte(builtinChains), chains); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range chains {
+		chain, err := LoadChain(name, "")
+		if err != nil {
+			t.Errorf("chain '%s': %s", name, err)
+			continue
+		}
+
+		if len(chain.Currency) == 0 || len(chain.Database) == 0 || len(chain.Storage) == 0 || chain.Unit != DefaultPrecision {
+			t.Errorf("chain '%s' profile is missing settings: %+v", name, chain)
+		}
+
+		//the built in profiles are checked against the chain
+		if err := chain.Complete(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, ok := chains[DefaultChain]; !ok {
+		t.Errorf("default chain '%s' is not built in", DefaultChain)
+	}
+}
+
+func TestChainComplete(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "chains.yaml")
+	config := "mychain:\n  genesis: \"100000000\"\n  blockreward: \"1\"\n  currency: MYC\n" +
+		"partial:\n  genesis: \"100000000\"\n  currency: MYC\n"
+	if err := ioutil.WriteFile(p, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := LoadChain("mychain", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Complete(); err != nil {
+		t.Error(err)
+	}
+
+	if chain.Genesis.String() != "100000000000000000" || chain.BlockReward.String() != "1000000000" {
+		t.Errorf("got genesis %s and block reward %s, expecting 100000000000000000 and 1000000000", chain.Genesis, chain.BlockReward)
+	}
+
+	if chain, err = LoadChain("partial", p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.Complete(); err == nil || err.Error() != "chain 'partial' profile has no blockreward" {
+		t.Errorf("got complete error '%v', expecting the missing block reward", err)
+	}
+
+	//the networks without a known genesis and block reward are not built in
+	if _, err := LoadChain("tfchain-testnet", ""); err == nil {
+		t.Error("expecting an error for the tfchain testnet without a chains config")
+	}
+}
@@ -0,0 +1,423 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+type ConditionType int
+
+const (
+	NilCondtion ConditionType = iota
+	UnlockHashCondition
+	AtomicSwapCondition
+	TimeLockCondition
+	MultiSignatureCondition
+)
+
+const (
+	//UnlockHashLength is the length of a hex encoded unlock hash, which is made of its type (1 byte),
+	//hash (32 bytes) and checksum (6 bytes)
+	UnlockHashLength = 78
+	//maxUnlockType is the highest known unlock hash type, the types are nil (0), public key (1),
+	//atomic swap (2) and multisignature (3)
+	maxUnlockType = 3
+
+	unlockTypeNil        = 0
+	unlockTypeAtomicSwap = 2
+	unlockTypeMultiSig   = 3
+)
+
+//ValidateUnlockHash checks the format of an unlock hash, the checksum is not verified
+func ValidateUnlockHash(hash string) error {
+	if len(hash) != UnlockHashLength {
+		return fmt.Errorf("invalid unlock hash '%s', expecting %d hex characters", hash, UnlockHashLength)
+	}
+
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return fmt.Errorf("invalid unlock hash '%s': %v", hash, err)
+	}
+
+	if raw[0] > maxUnlockType {
+		return fmt.Errorf("invalid unlock hash '%s', unknown type %d", hash, raw[0])
+	}
+
+	return nil
+}
+
+//encodeUnlockHash returns the hex unlock hash of the given type and hash, followed by its checksum which
+//is the start of the hash of the type and hash
+func encodeUnlockHash(t byte, hash [32]byte) string {
+	raw := append([]byte{t}, hash[:]...)
+	checksum := blake2b.Sum256(raw)
+	return hex.EncodeToString(raw) + hex.EncodeToString(checksum[:6])
+}
+
+//decodeUnlockHash returns the binary type and hash of an unlock hash, without its checksum
+func decodeUnlockHash(hash string) ([]byte, error) {
+	if err := ValidateUnlockHash(hash); err != nil {
+		return nil, err
+	}
+
+	raw, _ := hex.DecodeString(hash)
+	return raw[:33], nil
+}
+
+//merkleRoot returns the root of the merkle tree of the leaves, as built by rivine: the leaves and the nodes
+//are hashed with a 0 and 1 prefix, and the subtrees left over by an uneven number of leaves are joined
+//from right to left
+func merkleRoot(leaves [][]byte) [32]byte {
+	type subtree struct {
+		height int
+		sum    [32]byte
+	}
+
+	node := func(left, right [32]byte) [32]byte {
+		return blake2b.Sum256(append(append([]byte{1}, left[:]...), right[:]...))
+	}
+
+	var stack []subtree
+	for _, leaf := range leaves {
+		tree := subtree{sum: blake2b.Sum256(append([]byte{0}, leaf...))}
+		for len(stack) > 0 && stack[len(stack)-1].height == tree.height {
+			tree = subtree{height: tree.height + 1, sum: node(stack[len(stack)-1].sum, tree.sum)}
+			stack = stack[:len(stack)-1]
+		}
+
+		stack = append(stack, tree)
+	}
+
+	if len(stack) == 0 {
+		return [32]byte{}
+	}
+
+	root := stack[len(stack)-1].sum
+	for i := len(stack) - 2; i >= 0; i-- {
+		root = node(stack[i].sum, root)
+	}
+
+	return root
+}
+
+//uint64Bytes encodes an integer as rivine does, 8 bytes little endian
+func uint64Bytes(v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return b[:]
+}
+
+//ConditionData is the decoded data of a condition, the data types are NilConditionData,
+//UnlockHashConditionData, AtomicSwapConditionData, TimeLockConditionData and MultiSignatureConditionData
+type ConditionData interface {
+	ConditionType() ConditionType
+}
+
+//NilConditionData data of a nil condition, anyone can spend the output
+type NilConditionData struct{}
+
+//ConditionType returns NilCondtion
+func (d NilConditionData) ConditionType() ConditionType {
+	return NilCondtion
+}
+
+type UnlockHashConditionData struct {
+	UnlockHash string `json:"unlockhash"`
+}
+
+//ConditionType returns UnlockHashCondition
+func (d UnlockHashConditionData) ConditionType() ConditionType {
+	return UnlockHashCondition
+}
+
+//UnmarshalJSON decodes and validates the unlock hash
+func (d *UnlockHashConditionData) UnmarshalJSON(text []byte) error {
+	type data UnlockHashConditionData
+	if err := json.Unmarshal(text, (*data)(d)); err != nil {
+		return err
+	}
+
+	return ValidateUnlockHash(d.UnlockHash)
+}
+
+type AtomicSwapConditionData struct {
+	Sender       string `json:"sender"`
+	Receiver     string `json:"receiver"`
+	HashedSecret string `json:"hashedsecret"`
+	TimeLock     int64  `json:"timelock"`
+}
+
+//ConditionType returns AtomicSwapCondition
+func (d AtomicSwapConditionData) ConditionType() ConditionType {
+	return AtomicSwapCondition
+}
+
+//UnmarshalJSON decodes and validates the sender and receiver unlock hashes, and the hashed secret
+func (d *AtomicSwapConditionData) UnmarshalJSON(text []byte) error {
+	type data AtomicSwapConditionData
+	if err := json.Unmarshal(text, (*data)(d)); err != nil {
+		return err
+	}
+
+	if err := ValidateUnlockHash(d.Sender); err != nil {
+		return fmt.Errorf("sender: %v", err)
+	}
+
+	if err := ValidateUnlockHash(d.Receiver); err != nil {
+		return fmt.Errorf("receiver: %v", err)
+	}
+
+	if secret, err := hex.DecodeString(d.HashedSecret); err != nil || len(secret) != 32 {
+		return fmt.Errorf("invalid hashed secret '%s', expecting 64 hex characters", d.HashedSecret)
+	}
+
+	return nil
+}
+
+//binary returns the rivine (siabin) encoding of the contract: the sender and receiver type and hash
+//without checksum, the hashed secret and the time lock as 8 bytes little endian
+func (d AtomicSwapConditionData) binary() ([]byte, error) {
+	var b bytes.Buffer
+	for _, hash := range []string{d.Sender, d.Receiver} {
+		raw, err := decodeUnlockHash(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(raw)
+	}
+
+	secret, err := hex.DecodeString(d.HashedSecret)
+	if err != nil || len(secret) != 32 {
+		return nil, fmt.Errorf("invalid hashed secret '%s', expecting 64 hex characters", d.HashedSecret)
+	}
+
+	b.Write(secret)
+	b.Write(uint64Bytes(uint64(d.TimeLock)))
+	return b.Bytes(), nil
+}
+
+//UnlockHash returns the unlock hash of the contract. Rivine hashes the encoded contract as an object,
+//so the hash is of its siabin encoding as a byte slice: the length of the encoded contract as 8 bytes
+//little endian followed by the encoded contract, which is also how the condition is encoded in the
+//transactions after its type.
+func (d AtomicSwapConditionData) UnlockHash() (string, error) {
+	encoded, err := d.binary()
+	if err != nil {
+		return "", err
+	}
+
+	object := append(uint64Bytes(uint64(len(encoded))), encoded...)
+	return encodeUnlockHash(unlockTypeAtomicSwap, blake2b.Sum256(object)), nil
+}
+
+//LockTimeMinTimestampValue is the smallest lock time that is interpreted as a unix timestamp,
+//lower lock times are block heights
+const LockTimeMinTimestampValue = 500 * 1000 * 1000
+
+type TimeLockConditionData struct {
+	LockTime  int64     `json:"locktime"`
+	Condition Condition `json:"condition"`
+}
+
+//ConditionType returns TimeLockCondition
+func (d TimeLockConditionData) ConditionType() ConditionType {
+	return TimeLockCondition
+}
+
+//UnmarshalJSON decodes the time lock and its condition, which can only be a nil, unlock hash
+//or multisignature condition
+func (d *TimeLockConditionData) UnmarshalJSON(text []byte) error {
+	type data TimeLockConditionData
+	if err := json.Unmarshal(text, (*data)(d)); err != nil {
+		return err
+	}
+
+	switch d.Condition.Type {
+	case NilCondtion, UnlockHashCondition, MultiSignatureCondition:
+		return nil
+	}
+
+	return fmt.Errorf("invalid time locked condition type %d", d.Condition.Type)
+}
+
+//Unlocked checks if the lock time has passed at the given block
+func (d *TimeLockConditionData) Unlocked(blk *Block) bool {
+	if d.LockTime < LockTimeMinTimestampValue {
+		return d.LockTime <= blk.Height
+	}
+
+	return d.LockTime <= blk.RawBlock.Timestamp
+}
+
+type MultiSignatureConditionData struct {
+	UnlockHashes          []string `json:"unlockhashes"`
+	MinimumSignatureCount int      `json:"minimumsignaturecount"`
+}
+
+//ConditionType returns MultiSignatureCondition
+func (d MultiSignatureConditionData) ConditionType() ConditionType {
+	return MultiSignatureCondition
+}
+
+//UnmarshalJSON decodes and validates the owners unlock hashes and the minimum signature count
+func (d *MultiSignatureConditionData) UnmarshalJSON(text []byte) error {
+	type data MultiSignatureConditionData
+	if err := json.Unmarshal(text, (*data)(d)); err != nil {
+		return err
+	}
+
+	for i, hash := range d.UnlockHashes {
+		if err := ValidateUnlockHash(hash); err != nil {
+			return fmt.Errorf("owner (%d): %v", i, err)
+		}
+	}
+
+	if d.MinimumSignatureCount < 1 || d.MinimumSignatureCount > len(d.UnlockHashes) {
+		return fmt.Errorf("invalid minimum signature count %d of %d owners", d.MinimumSignatureCount, len(d.UnlockHashes))
+	}
+
+	return nil
+}
+
+//UnlockHash returns the address of the multisignature wallet, the merkle root of the number of owners,
+//the sorted owners unlock hashes and the minimum signature count. It doesn't depend on the order of the owners.
+func (d MultiSignatureConditionData) UnlockHash() (string, error) {
+	owners := make([][]byte, 0, len(d.UnlockHashes))
+	for i, hash := range d.UnlockHashes {
+		raw, err := decodeUnlockHash(hash)
+		if err != nil {
+			return "", fmt.Errorf("owner (%d): %v", i, err)
+		}
+
+		owners = append(owners, raw)
+	}
+
+	sort.Slice(owners, func(i, j int) bool {
+		return bytes.Compare(owners[i], owners[j]) < 0
+	})
+
+	leaves := [][]byte{uint64Bytes(uint64(len(owners)))}
+	leaves = append(leaves, owners...)
+	leaves = append(leaves, uint64Bytes(uint64(d.MinimumSignatureCount)))
+
+	return encodeUnlockHash(unlockTypeMultiSig, merkleRoot(leaves)), nil
+}
+
+//Condition of an output, the data is decoded according to the condition type once when the condition
+//is decoded, and can be accessed with Value or the typed accessors
+type Condition struct {
+	Type ConditionType   `json:"type"`
+	Data json.RawMessage `json:"data"`
+
+	value ConditionData
+}
+
+//NewCondition creates a condition from its data
+func NewCondition(data ConditionData) Condition {
+	raw, _ := json.Marshal(data)
+	return Condition{Type: data.ConditionType(), Data: raw, value: data}
+}
+
+//UnmarshalJSON decodes the condition data according to its type
+func (c *Condition) UnmarshalJSON(text []byte) error {
+	var body struct {
+		Type ConditionType   `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(text, &body); err != nil {
+		return err
+	}
+
+	var value ConditionData
+	var err error
+	switch body.Type {
+	case NilCondtion:
+		value = NilConditionData{}
+	case UnlockHashCondition:
+		var data UnlockHashConditionData
+		err = json.Unmarshal(body.Data, &data)
+		value = data
+	case AtomicSwapCondition:
+		var data AtomicSwapConditionData
+		err = json.Unmarshal(body.Data, &data)
+		value = data
+	case TimeLockCondition:
+		var data TimeLockConditionData
+		err = json.Unmarshal(body.Data, &data)
+		value = data
+	case MultiSignatureCondition:
+		var data MultiSignatureConditionData
+		err = json.Unmarshal(body.Data, &data)
+		value = data
+	default:
+		return fmt.Errorf("unknown condition type %d", body.Type)
+	}
+
+	if err != nil {
+		return fmt.Errorf("condition type %d: %v", body.Type, err)
+	}
+
+	*c = Condition{Type: body.Type, Data: body.Data, value: value}
+	return nil
+}
+
+//Value returns the decoded condition data
+func (c *Condition) Value() ConditionData {
+	if c.value == nil {
+		return NilConditionData{}
+	}
+
+	return c.value
+}
+
+func (c *Condition) typeError(expected ConditionType) error {
+	return fmt.Errorf("condition type is %d, expecting %d", c.Value().ConditionType(), expected)
+}
+
+//UnlockHashData returns the data of an unlock hash condition
+func (c *Condition) UnlockHashData() (UnlockHashConditionData, error) {
+	data, ok := c.Value().(UnlockHashConditionData)
+	if !ok {
+		return data, c.typeError(UnlockHashCondition)
+	}
+
+	return data, nil
+}
+
+//AtomicSwapData returns the data of an atomic swap condition
+func (c *Condition) AtomicSwapData() (AtomicSwapConditionData, error) {
+	data, ok := c.Value().(AtomicSwapConditionData)
+	if !ok {
+		return data, c.typeError(AtomicSwapCondition)
+	}
+
+	return data, nil
+}
+
+//TimeLockData returns the data of a time lock condition
+func (c *Condition) TimeLockData() (TimeLockConditionData, error) {
+	data, ok := c.Value().(TimeLockConditionData)
+	if !ok {
+		return data, c.typeError(TimeLockCondition)
+	}
+
+	return data, nil
+}
+
+//MultiSignatureData returns the data of a multisignature condition
+func (c *Condition) MultiSignatureData() (MultiSignatureConditionData, error) {
+	data, ok := c.Value().(MultiSignatureConditionData)
+	if !ok {
+		return data, c.typeError(MultiSignatureCondition)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,260 @@
+package reporter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	testUnlockHash1 = "010000000000000000000000000000000000000000000000000000000000000001731b24a8526a"
+	testUnlockHash2 = "010000000000000000000000000000000000000000000000000000000000000002737eb7d7d0ad"
+	testUnlockHash3 = "01000000000000000000000000000000000000000000000000000000000000000351f5ecefc341"
+)
+
+func TestEncodeUnlockHash(t *testing.T) {
+	var hash [32]byte
+	hash[31] = 1
+	if actual := encodeUnlockHash(1, hash); actual != testUnlockHash1 {
+		t.Errorf("encodeUnlockHash() = %s, expecting %s", actual, testUnlockHash1)
+	}
+}
+
+//the owners and address of a 2 of 2 multisignature wallet, as documented by rivine
+const (
+	multiSigOwner1  = "01e89843e4b8231a01ba18b254d530110364432aafab8206bea72e5a20eaa55f70b1ccc65e2105"
+	multiSigOwner2  = "01a6a6c5584b2bfbd08738996cd7930831f958b9a5ed1595525236e861c1a0dc353bdcf54be7d8"
+	multiSigAddress = "0313a5abd192d1bacdd1eb518fc86987d3c3d1cfe3c5bed68ec4a86b93b2f05a89f67b89b07d71"
+)
+
+func TestMultiSignatureUnlockHash(t *testing.T) {
+	cases := []string{
+		fmt.Sprintf(`{"type": 4, "data": {"unlockhashes": ["%s", "%s"], "minimumsignaturecount": 2}}`, multiSigOwner1, multiSigOwner2),
+		//the address doesn't depend on the order of the owners
+		fmt.Sprintf(`{"type": 4, "data": {"unlockhashes": ["%s", "%s"], "minimumsignaturecount": 2}}`, multiSigOwner2, multiSigOwner1),
+	}
+
+	for i, text := range cases {
+		var condition Condition
+		if err := json.Unmarshal([]byte(text), &condition); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := condition.MultiSignatureData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual, err := data.UnlockHash(); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		} else if actual != multiSigAddress {
+			t.Errorf("case %d: UnlockHash() = %s, expecting %s", i, actual, multiSigAddress)
+		}
+	}
+
+	//the checksum of an address is the start of the hash of its type and hash
+	for _, hash := range []string{multiSigOwner1, multiSigOwner2, multiSigAddress} {
+		raw, err := decodeUnlockHash(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sum [32]byte
+		copy(sum[:], raw[1:])
+		if actual := encodeUnlockHash(raw[0], sum); actual != hash {
+			t.Errorf("encodeUnlockHash() = %s, expecting %s", actual, hash)
+		}
+	}
+
+	data := MultiSignatureConditionData{UnlockHashes: []string{testUnlockHash1, "01ab"}, MinimumSignatureCount: 1}
+	if _, err := data.UnlockHash(); err == nil {
+		t.Error("expecting an error for an invalid owner")
+	}
+}
+
+func TestAtomicSwapUnlockHash(t *testing.T) {
+	//the atomic swap condition example of the rivine documentation
+	data := AtomicSwapConditionData{
+		Sender:       multiSigOwner1,
+		Receiver:     multiSigOwner2,
+		HashedSecret: "abc543defabc543defabc543defabc543defabc543defabc543defabc543defa",
+		TimeLock:     1522068743,
+	}
+
+	//the condition as encoded in the transactions: its type, the length of the contract and the contract,
+	//its unlock hash is the hash of the encoding without the type
+	const condition = "02" + "6a00000000000000" +
+		"01e89843e4b8231a01ba18b254d530110364432aafab8206bea72e5a20eaa55f70" +
+		"01a6a6c5584b2bfbd08738996cd7930831f958b9a5ed1595525236e861c1a0dc35" +
+		"abc543defabc543defabc543defabc543defabc543defabc543defabc543defa" +
+		"07edb85a00000000"
+
+	if encoded, err := data.binary(); err != nil {
+		t.Fatal(err)
+	} else if actual := "02" + hex.EncodeToString(uint64Bytes(uint64(len(encoded)))) + hex.EncodeToString(encoded); actual != condition {
+		t.Errorf("got condition encoded as %s, expecting %s", actual, condition)
+	}
+
+	const expected = "026e18a53ec6e571985ea7ed404a5d51cf03a72240065952034383100738627dbf949046789e30"
+	if actual, err := data.UnlockHash(); err != nil {
+		t.Fatal(err)
+	} else if actual != expected {
+		t.Errorf("UnlockHash() = %s, expecting %s", actual, expected)
+	}
+
+	data.HashedSecret = "ff"
+	if _, err := data.UnlockHash(); err == nil {
+		t.Error("expecting an error for an invalid hashed secret")
+	}
+}
+
+func TestValidateUnlockHash(t *testing.T) {
+	cases := []struct {
+		hash string
+		err  string
+	}{
+		{testUnlockHash1, ""},
+		{"000000000000000000000000000000000000000000000000000000000000000000000000000000", ""},
+		{"030000000000000000000000000000000000000000000000000000000000000001731b24a8526a", ""},
+		{"", "expecting 78 hex characters"},
+		{testUnlockHash1[:76], "expecting 78 hex characters"},
+		{testUnlockHash1 + "00", "expecting 78 hex characters"},
+		{"zz" + testUnlockHash1[2:], "invalid byte"},
+		{"04" + testUnlockHash1[2:], "unknown type 4"},
+	}
+
+	for _, c := range cases {
+		err := ValidateUnlockHash(c.hash)
+		if len(c.err) == 0 && err != nil {
+			t.Errorf("ValidateUnlockHash(%q): %v", c.hash, err)
+		} else if len(c.err) != 0 && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("ValidateUnlockHash(%q) = %v, expecting an error with %q", c.hash, err, c.err)
+		}
+	}
+}
+
+func TestConditionUnmarshalJSON(t *testing.T) {
+	unlockHash := func(hash string) string {
+		return fmt.Sprintf(`{"type": 1, "data": {"unlockhash": "%s"}}`, hash)
+	}
+
+	multiSig := func(count int, hashes ...string) string {
+		return fmt.Sprintf(`{"type": 4, "data": {"unlockhashes": ["%s"], "minimumsignaturecount": %d}}`,
+			strings.Join(hashes, `", "`), count)
+	}
+
+	swap := func(sender, secret string) string {
+		return fmt.Sprintf(`{"type": 2, "data": {"sender": "%s", "receiver": "%s", "hashedsecret": "%s", "timelock": 1600000000}}`,
+			sender, testUnlockHash2, secret)
+	}
+
+	timeLock := func(condition string) string {
+		return fmt.Sprintf(`{"type": 3, "data": {"locktime": 100, "condition": %s}}`, condition)
+	}
+
+	secret := strings.Repeat("ab", 32)
+	cases := []struct {
+		name      string
+		text      string
+		condition ConditionType
+		err       string
+	}{
+		{"nil", `{"type": 0, "data": {}}`, NilCondtion, ""},
+		{"nil without data", `{"type": 0}`, NilCondtion, ""},
+		{"unlock hash", unlockHash(testUnlockHash1), UnlockHashCondition, ""},
+		{"invalid unlock hash", unlockHash("01ab"), 0, "condition type 1: invalid unlock hash '01ab'"},
+		{"atomic swap", swap(testUnlockHash1, secret), AtomicSwapCondition, ""},
+		{"atomic swap invalid sender", swap("01ab", secret), 0, "condition type 2: sender: invalid unlock hash '01ab'"},
+		{"atomic swap invalid secret", swap(testUnlockHash1, "ab"), 0, "condition type 2: invalid hashed secret 'ab'"},
+		{"multisignature", multiSig(2, testUnlockHash1, testUnlockHash2), MultiSignatureCondition, ""},
+		{"multisignature invalid owner", multiSig(1, testUnlockHash1, "01ab"), 0, "condition type 4: owner (1): invalid unlock hash '01ab'"},
+		{"multisignature invalid count", multiSig(3, testUnlockHash1, testUnlockHash2), 0, "invalid minimum signature count 3 of 2 owners"},
+		{"time locked nil", timeLock(`{"type": 0}`), TimeLockCondition, ""},
+		{"time locked unlock hash", timeLock(unlockHash(testUnlockHash1)), TimeLockCondition, ""},
+		{"time locked multisignature", timeLock(multiSig(1, testUnlockHash1, testUnlockHash2)), TimeLockCondition, ""},
+		{"time locked invalid unlock hash", timeLock(unlockHash("01ab")), 0, "condition type 3: condition type 1: invalid unlock hash '01ab'"},
+		{"time locked atomic swap", timeLock(swap(testUnlockHash1, secret)), 0, "invalid time locked condition type 2"},
+		{"nested time lock", timeLock(timeLock(unlockHash(testUnlockHash1))), 0, "invalid time locked condition type 3"},
+		{"unknown type", `{"type": 5, "data": {}}`, 0, "unknown condition type 5"},
+	}
+
+	for _, c := range cases {
+		var condition Condition
+		err := json.Unmarshal([]byte(c.text), &condition)
+		if len(c.err) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, expecting %q", c.name, err, c.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if actual := condition.Value().ConditionType(); actual != c.condition {
+			t.Errorf("%s: got condition type %d, expecting %d", c.name, actual, c.condition)
+		}
+	}
+
+	//the time locked condition is decoded with the time lock
+	var condition Condition
+	if err := json.Unmarshal([]byte(timeLock(unlockHash(testUnlockHash1))), &condition); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := condition.TimeLockData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := lock.Condition.UnlockHashData(); err != nil {
+		t.Error(err)
+	} else if lock.LockTime != 100 || data.UnlockHash != testUnlockHash1 {
+		t.Errorf("got time lock %d on %s, expecting 100 on %s", lock.LockTime, data.UnlockHash, testUnlockHash1)
+	}
+}
+
+func TestInputOutputsUnmarshalJSON(t *testing.T) {
+	output := func(hash, condition string) string {
+		return fmt.Sprintf(`{"value": "10", "unlockhash": "%s", "condition": %s}`, hash, condition)
+	}
+
+	condition := fmt.Sprintf(`{"type": 1, "data": {"unlockhash": "%s"}}`, testUnlockHash1)
+	cases := []struct {
+		name    string
+		text    string
+		outputs int
+		err     string
+	}{
+		{"null", `null`, 0, ""},
+		{"empty", `[]`, 0, ""},
+		{"outputs", "[" + output(testUnlockHash1, condition) + ", " + output("", `{"type": 0}`) + "]", 2, ""},
+		{"invalid unlock hash", "[" + output(testUnlockHash1, condition) + ", " + output("01ab", condition) + "]", 0,
+			"output (1): invalid unlock hash '01ab'"},
+		{"invalid condition", "[" + output(testUnlockHash1, `{"type": 1, "data": {"unlockhash": "01ab"}}`) + "]", 0,
+			"output (0): condition type 1: invalid unlock hash '01ab'"},
+		{"invalid value", `[{"value": "ten", "condition": {"type": 0}}]`, 0, "output (0): "},
+	}
+
+	for _, c := range cases {
+		var outputs InputOutputs
+		err := json.Unmarshal([]byte(c.text), &outputs)
+		if len(c.err) != 0 {
+			if _, ok := err.(DataError); !ok {
+				t.Errorf("%s: got error %v, expecting a data error", c.name, err)
+			} else if !strings.HasPrefix(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, expecting %q", c.name, err, c.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if len(outputs) != c.outputs {
+			t.Errorf("%s: got %d outputs, expecting %d", c.name, len(outputs), c.outputs)
+		}
+	}
+}
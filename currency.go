@@ -0,0 +1,198 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	//sortableWidth is the number of digits of a sortable currency string
+	sortableWidth = 40
+)
+
+var (
+	sortableBase = new(big.Int).Exp(big.NewInt(10), big.NewInt(sortableWidth), nil)
+)
+
+//Currency is an exact amount of hastings, the smallest unit of a coin
+type Currency struct {
+	i *big.Int
+}
+
+//NewCurrency creates a currency from a big int
+func NewCurrency(i *big.Int) Currency {
+	return Currency{i: new(big.Int).Set(i)}
+}
+
+//NewCurrency64 creates a currency from an int64
+func NewCurrency64(v int64) Currency {
+	return Currency{i: big.NewInt(v)}
+}
+
+//ParseCurrency parses a decimal amount of hastings
+func ParseCurrency(s string) (Currency, error) {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return Currency{}, fmt.Errorf("invalid currency value '%s'", s)
+	}
+
+	return Currency{i: i}, nil
+}
+
+//Big returns a copy of the currency value as big int
+func (c Currency) Big() *big.Int {
+	if c.i == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(c.i)
+}
+
+//Add returns c + o
+func (c Currency) Add(o Currency) Currency {
+	return Currency{i: new(big.Int).Add(c.Big(), o.Big())}
+}
+
+//Sub returns c - o
+func (c Currency) Sub(o Currency) Currency {
+	return Currency{i: new(big.Int).Sub(c.Big(), o.Big())}
+}
+
+//Mul64 returns c * v
+func (c Currency) Mul64(v int64) Currency {
+	return Currency{i: new(big.Int).Mul(c.Big(), big.NewInt(v))}
+}
+
+//Cmp compares c and o, and returns -1, 0 or 1
+func (c Currency) Cmp(o Currency) int {
+	return c.Big().Cmp(o.Big())
+}
+
+//Sign returns -1, 0 or 1 depending on the sign of c
+func (c Currency) Sign() int {
+	return c.Big().Sign()
+}
+
+//Int64 returns the currency as int64, or an error if it doesn't fit
+func (c Currency) Int64() (int64, error) {
+	i := c.Big()
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("currency value '%s' overflows int64", i)
+	}
+
+	return i.Int64(), nil
+}
+
+//String returns the decimal amount of hastings
+func (c Currency) String() string {
+	return c.Big().String()
+}
+
+//MarshalJSON encodes the currency as a string like rivine does
+func (c Currency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+//UnmarshalJSON decodes the currency from a string or a number
+func (c *Currency) UnmarshalJSON(text []byte) error {
+	text = bytes.Trim(text, `"`)
+	value, err := ParseCurrency(string(text))
+	if err != nil {
+		return err
+	}
+
+	*c = value
+	return nil
+}
+
+//sortable returns a fixed width string representation of the currency where the lexical
+//order is the same as the numeric order, so amounts can be compared and sorted as sqlite text.
+//Amounts from -10^40 to 10^40-1 hastings are represented exactly, the amounts out of this range
+//(way above any coin supply, so only query bounds) are clamped to it.
+func (c Currency) sortable() string {
+	i := c.Big()
+	if i.Cmp(sortableBase) >= 0 {
+		i.Sub(sortableBase, big.NewInt(1))
+	} else if i.CmpAbs(sortableBase) > 0 {
+		i.Neg(sortableBase)
+	}
+
+	prefix := "1"
+	if i.Sign() < 0 {
+		prefix = "0"
+		i.Add(i, sortableBase)
+	}
+
+	return fmt.Sprintf("%s%0*s", prefix, sortableWidth, i)
+}
+
+//parseSortable parses a value created with sortable
+func parseSortable(s string) (Currency, error) {
+	if len(s) != sortableWidth+1 {
+		return Currency{}, fmt.Errorf("invalid sortable currency value '%s'", s)
+	}
+
+	if s[0] != '0' && s[0] != '1' || strings.Trim(s[1:], "0123456789") != "" {
+		return Currency{}, fmt.Errorf("invalid sortable currency value '%s'", s)
+	}
+
+	c, err := ParseCurrency(strings.TrimLeft(s[1:], "0") + "0")
+	if err != nil {
+		return c, err
+	}
+
+	//we appended a 0 digit to not end up with an empty string
+	c.i.Div(c.i, big.NewInt(10))
+	if s[0] == '0' {
+		c.i.Sub(c.i, sortableBase)
+	}
+
+	return c, nil
+}
+
+//Unit formats and parses decimal amounts of coins, where one coin is 10^Unit hastings
+type Unit uint
+
+func (u Unit) coin() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(u)), nil)
+}
+
+//Format returns the exact decimal amount of coins of the given currency
+func (u Unit) Format(c Currency) string {
+	q, r := new(big.Int).QuoRem(c.Big(), u.coin(), new(big.Int))
+	if r.Sign() == 0 {
+		return q.String()
+	}
+
+	sign := ""
+	if c.Sign() < 0 {
+		sign = "-"
+	}
+
+	fraction := strings.TrimRight(fmt.Sprintf("%0*s", int(u), r.Abs(r)), "0")
+	return fmt.Sprintf("%s%s.%s", sign, q.Abs(q), fraction)
+}
+
+//Parse parses a decimal amount of coins, amounts with more decimals than the unit are refused
+//instead of being rounded
+func (u Unit) Parse(s string) (Currency, error) {
+	if strings.Trim(s, "+-.") == "" {
+		return Currency{}, fmt.Errorf("invalid amount '%s'", s)
+	}
+
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) == 2 {
+		if len(parts[1]) > int(u) {
+			return Currency{}, fmt.Errorf("invalid amount '%s', max %d decimals", s, u)
+		}
+
+		s = parts[0] + parts[1] + strings.Repeat("0", int(u)-len(parts[1]))
+	} else {
+		s += strings.Repeat("0", int(u))
+	}
+
+	return ParseCurrency(s)
+}
@@ -0,0 +1,142 @@
+package reporter
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUnitFormat(t *testing.T) {
+	cases := []struct {
+		unit     Unit
+		value    string
+		expected string
+	}{
+		{9, "0", "0"},
+		{9, "1000000000", "1"},
+		{9, "1500000000", "1.5"},
+		{9, "1", "0.000000001"},
+		{9, "-1500000000", "-1.5"},
+		{9, "-1", "-0.000000001"},
+		{9, "123456789012345678901234567890", "123456789012345678901.23456789"},
+		{0, "-42", "-42"},
+	}
+
+	for _, c := range cases {
+		value, err := ParseCurrency(c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if formatted := c.unit.Format(value); formatted != c.expected {
+			t.Errorf("got %s formatted with unit %d as %s, expecting %s", c.value, c.unit, formatted, c.expected)
+		}
+	}
+}
+
+func TestUnitParse(t *testing.T) {
+	cases := []struct {
+		unit     Unit
+		amount   string
+		expected string
+	}{
+		{9, "1", "1000000000"},
+		{9, "1.5", "1500000000"},
+		{9, "0.000000001", "1"},
+		{9, ".5", "500000000"},
+		{9, "-1.5", "-1500000000"},
+		{9, "-0.000000001", "-1"},
+		{9, "123456789012345678901.23456789", "123456789012345678901234567890"},
+		{0, "42", "42"},
+	}
+
+	for _, c := range cases {
+		value, err := c.unit.Parse(c.amount)
+		if err != nil {
+			t.Errorf("parse %s with unit %d: %s", c.amount, c.unit, err)
+		} else if value.String() != c.expected {
+			t.Errorf("got %s parsed with unit %d as %s, expecting %s", c.amount, c.unit, value, c.expected)
+		}
+
+		//parsing is exact, so formatting the value gives the amount back
+		if formatted := c.unit.Format(value); strings.TrimPrefix(formatted, "0") != strings.TrimPrefix(c.amount, "0") {
+			t.Errorf("got %s formatted back as %s", c.amount, formatted)
+		}
+	}
+
+	//amounts more precise than the unit are not rounded
+	for _, amount := range []string{"0.0000000001", "1.0000000005", "", ".", "-", "1.2.3", "1e9", "0x10"} {
+		if value, err := Unit(9).Parse(amount); err == nil {
+			t.Errorf("parsed invalid amount '%s' as %s", amount, value)
+		}
+	}
+
+	if value, err := Unit(0).Parse("1.5"); err == nil {
+		t.Errorf("parsed 1.5 with unit 0 as %s", value)
+	}
+}
+
+func TestSortable(t *testing.T) {
+	max := new(big.Int).Sub(sortableBase, big.NewInt(1))
+	min := new(big.Int).Neg(sortableBase)
+
+	var values []Currency
+	for _, v := range []string{"0", "1", "-1", "9", "10", "-10", "1000000000", "-999999999", "123456789012345678901234567890"} {
+		value, err := ParseCurrency(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values = append(values, value)
+	}
+
+	values = append(values, NewCurrency(max), NewCurrency(min))
+
+	var encoded []string
+	for _, value := range values {
+		s := value.sortable()
+		if len(s) != sortableWidth+1 {
+			t.Errorf("got %s encoded as '%s' of %d characters, expecting %d", value, s, len(s), sortableWidth+1)
+		}
+
+		decoded, err := parseSortable(s)
+		if err != nil {
+			t.Errorf("parse '%s': %s", s, err)
+		} else if decoded.Cmp(value) != 0 {
+			t.Errorf("got %s decoded as %s", value, decoded)
+		}
+
+		encoded = append(encoded, s)
+	}
+
+	//the lexical order of the encoded values is their numeric order
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) < 0
+	})
+
+	sort.Strings(encoded)
+	for i, s := range encoded {
+		if decoded, _ := parseSortable(s); decoded.Cmp(values[i]) != 0 {
+			t.Errorf("got %s at sorted position %d, expecting %s", decoded, i, values[i])
+		}
+	}
+
+	//values out of range are clamped, so they still sort after or before all the others
+	over := NewCurrency(sortableBase).Mul64(10)
+	if s := over.sortable(); s != NewCurrency(max).sortable() {
+		t.Errorf("got %s encoded as '%s', expecting the max value", over, s)
+	}
+
+	under := NewCurrency(min).Sub(NewCurrency64(1))
+	if s := under.sortable(); s != NewCurrency(min).sortable() {
+		t.Errorf("got %s encoded as '%s', expecting the min value", under, s)
+	}
+
+	zeros := strings.Repeat("0", sortableWidth-1)
+	for _, s := range []string{"", "1", "1" + zeros, "20" + zeros, "1-" + zeros, "1+" + zeros, "1a" + zeros} {
+		if value, err := parseSortable(s); err == nil {
+			t.Errorf("parsed invalid sortable value '%s' as %s", s, value)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package reporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	daemonBlockEndpoint = "consensus/blocks"
+	daemonChainEndpoint = "consensus"
+)
+
+//daemonTransaction is a transaction as returned by the daemon consensus api
+type daemonTransaction struct {
+	ID                     string             `json:"id"`
+	Version                TransactionVersion `json:"version"`
+	Data                   json.RawMessage    `json:"data"`
+	CoinOutputIDs          []string           `json:"coinoutputids"`
+	CoinOutputUnlockHashes []string           `json:"coinoutputunlockhashes"`
+
+	BlockStakeOutputIDs          []string `json:"blockstakeoutputids"`
+	BlockStakeOutputUnlockHashes []string `json:"blockstakeunlockhashes"`
+}
+
+//daemonBlock is a block as returned by the daemon consensus api
+type daemonBlock struct {
+	ID     string `json:"id"`
+	Height int64  `json:"height"`
+	Header struct {
+		ParentID       string   `json:"parentid"`
+		Timestamp      int64    `json:"timestamp"`
+		MinerPayoutIDs []string `json:"minerpayoutids"`
+	} `json:"header"`
+	MinerPayouts InputOutputs        `json:"minerpayouts"`
+	Transactions []daemonTransaction `json:"transactions"`
+}
+
+//unlockHash returns the unlock hash of an output, it's derived from the condition if the daemon didn't
+//return it. Outputs with a nil condition have no unlock hash.
+func unlockHash(output *InputOutput) (string, error) {
+	if len(output.UnlockHash) != 0 {
+		return output.UnlockHash, nil
+	}
+
+	switch data := output.Condition.Value().(type) {
+	case UnlockHashConditionData:
+		return data.UnlockHash, nil
+	case TimeLockConditionData:
+		//a time lock has the unlock hash of its condition
+		return unlockHash(&InputOutput{Condition: data.Condition})
+	case AtomicSwapConditionData:
+		return data.UnlockHash()
+	case MultiSignatureConditionData:
+		return data.UnlockHash()
+	}
+
+	return "", nil
+}
+
+func (b *daemonBlock) block() (*Block, error) {
+	blk := &Block{
+		ID:             b.ID,
+		Height:         b.Height,
+		MinerPayoutIDs: b.Header.MinerPayoutIDs,
+	}
+
+	blk.RawBlock.ParentID = b.Header.ParentID
+	blk.RawBlock.Timestamp = b.Header.Timestamp
+	blk.RawBlock.MinerPayouts = b.MinerPayouts
+
+	for _, t := range b.Transactions {
+		txn := Transaction{
+			ID:                     t.ID,
+			Height:                 b.Height,
+			Parent:                 b.ID,
+			CoinOutputIDs:          t.CoinOutputIDs,
+			CoinOutputUnlockHashes: t.CoinOutputUnlockHashes,
+
+			BlockStakeOutputIDs:          t.BlockStakeOutputIDs,
+			BlockStakeOutputUnlockHashes: t.BlockStakeOutputUnlockHashes,
+		}
+
+		if err := txn.RawTransaction.decode(t.Version, t.Data); err != nil {
+			return nil, dataError(err, "block (%d): transaction '%s'", b.Height, t.ID)
+		}
+
+		for i := len(txn.CoinOutputUnlockHashes); i < len(txn.RawTransaction.Data.CoinOutputs); i++ {
+			hash, err := unlockHash(&txn.RawTransaction.Data.CoinOutputs[i])
+			if err != nil {
+				return nil, dataError(err, "block (%d): transaction '%s': coin output (%d)", b.Height, t.ID, i)
+			}
+
+			txn.CoinOutputUnlockHashes = append(txn.CoinOutputUnlockHashes, hash)
+		}
+
+		for i := len(txn.BlockStakeOutputUnlockHashes); i < len(txn.RawTransaction.Data.BlockStakeOutputs); i++ {
+			hash, err := unlockHash(&txn.RawTransaction.Data.BlockStakeOutputs[i])
+			if err != nil {
+				return nil, dataError(err, "block (%d): transaction '%s': block stake output (%d)", b.Height, t.ID, i)
+			}
+
+			txn.BlockStakeOutputUnlockHashes = append(txn.BlockStakeOutputUnlockHashes, hash)
+		}
+
+		blk.Transactions = append(blk.Transactions, txn)
+	}
+
+	return blk, nil
+}
+
+//daemonClient gets the blocks from the daemon consensus api, without the spent outputs of the inputs
+type daemonClient struct {
+	*httpExplorer
+}
+
+func (e *daemonClient) GetBlock(ctx context.Context, h int64) (*Block, error) {
+	var body daemonBlock
+	if err := e.get(ctx, fmt.Sprintf("%s?height=%d", daemonBlockEndpoint, h), &body); err != nil {
+		if eerr, ok := err.(ExplorerError); ok && eerr.Code == http.StatusBadRequest {
+			//the daemon doesn't have a distinct error for blocks above the chain height
+			if height, herr := e.ChainHeight(ctx); herr == nil && h > height {
+				return nil, noBlockFound(h)
+			}
+		}
+
+		if _, ok := err.(DataError); ok {
+			return nil, dataError(err, "block (%d)", h)
+		}
+
+		return nil, err
+	}
+
+	return body.block()
+}
+
+func (e *daemonClient) ChainHeight(ctx context.Context) (int64, error) {
+	var body struct {
+		Height int64 `json:"height"`
+	}
+
+	if err := e.get(ctx, daemonChainEndpoint, &body); err != nil {
+		return 0, err
+	}
+
+	return body.Height, nil
+}
+
+func (e *daemonClient) Scan(head int64) Scanner {
+	return newScanner(e, head, e.opts)
+}
+
+//daemonExplorer reads the blocks from a rivine daemon, which unlike the explorer doesn't return the
+//outputs spent by the coin inputs. They are looked up in an output index that is updated while scanning.
+type daemonExplorer struct {
+	client *daemonClient
+	index  *OutputRecorder
+}
+
+//NewDaemonExplorer creates an explorer that reads the blocks from the consensus api of a rivine daemon,
+//the index keeps the outputs of all scanned blocks and must not be shared with the reporter recorders.
+//The url is daemon://host:port for a daemon served over http, or daemon+https://host:port over https.
+func NewDaemonExplorer(u string, opts ExplorerOptions, index *OutputRecorder) (Explorer, error) {
+	uri, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	//the daemon is on the same url as an explorer would be
+	switch uri.Scheme {
+	case "daemon", "daemon+http":
+		uri.Scheme = "http"
+	case "daemon+https":
+		uri.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("invalid daemon url scheme '%s', expecting daemon, daemon+http or daemon+https", uri.Scheme)
+	}
+
+	exp, err := NewExplorer(uri.String(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &daemonExplorer{client: &daemonClient{exp.(*httpExplorer)}, index: index}, nil
+}
+
+//spent returns the outputs spent by the inputs, from the outputs created earlier in the same block or
+//in the index
+func spent(inputs []CoinInput, created map[string]InputOutput, index func(id string) (Output, error)) ([]InputOutput, error) {
+	var outputs []InputOutput
+	for _, input := range inputs {
+		output, ok := created[input.ParentID]
+		if !ok {
+			indexed, err := index(input.ParentID)
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("input '%s' spends an unknown output", input.ParentID)
+			} else if err != nil {
+				return nil, err
+			}
+
+			output = InputOutput{Value: indexed.Value, UnlockHash: indexed.UnlockHash, Condition: indexed.Condition}
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	return outputs, nil
+}
+
+//create adds the outputs to the created outputs by id
+func create(outputs []InputOutput, ids, hashes []string, created map[string]InputOutput) {
+	for o, output := range outputs {
+		if o < len(ids) && o < len(hashes) {
+			output.UnlockHash = hashes[o]
+			created[ids[o]] = output
+		}
+	}
+}
+
+//resolve sets the spent outputs of the block coin and block stake inputs, from the outputs created
+//earlier in the same block or in the index
+func (e *daemonExplorer) resolve(blk *Block) error {
+	coins := make(map[string]InputOutput)
+	for i, payout := range blk.RawBlock.MinerPayouts {
+		if i < len(blk.MinerPayoutIDs) {
+			coins[blk.MinerPayoutIDs[i]] = payout
+		}
+	}
+
+	stakes := make(map[string]InputOutput)
+	for i := range blk.Transactions {
+		txn := &blk.Transactions[i]
+		data := &txn.RawTransaction.Data
+
+		var err error
+		if txn.CoinInputOutputs, err = spent(data.CoinInputs, coins, e.index.Output); err != nil {
+			return fmt.Errorf("transaction (%d): coin %v", i, err)
+		}
+
+		if txn.BlockStakeInputOutputs, err = spent(data.BlockStakeInputs, stakes, e.index.BlockStakeOutput); err != nil {
+			return fmt.Errorf("transaction (%d): block stake %v", i, err)
+		}
+
+		create(data.CoinOutputs, txn.CoinOutputIDs, txn.CoinOutputUnlockHashes, coins)
+		create(data.BlockStakeOutputs, txn.BlockStakeOutputIDs, txn.BlockStakeOutputUnlockHashes, stakes)
+	}
+
+	return nil
+}
+
+//GetBlock returns the block at height h, the outputs spent by its inputs must be in the index. So it only
+//returns the blocks up to the ones being scanned, which is why a daemon can't be used with other explorers.
+func (e *daemonExplorer) GetBlock(ctx context.Context, h int64) (*Block, error) {
+	blk, err := e.client.GetBlock(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return blk, e.resolve(blk)
+}
+
+func (e *daemonExplorer) ChainHeight(ctx context.Context) (int64, error) {
+	return e.client.ChainHeight(ctx)
+}
+
+func (e *daemonExplorer) Scan(head int64) Scanner {
+	return &daemonScanner{exp: e, head: head}
+}
+
+//daemonScanner indexes the outputs of all blocks in order, starting at the index cursor, and returns
+//the blocks starting at head
+type daemonScanner struct {
+	exp  *daemonExplorer
+	head int64
+	err  error
+}
+
+func (s *daemonScanner) Err() error {
+	return s.err
+}
+
+//start returns the height to start indexing at, the index is rolled back if it's ahead of the scanner
+func (s *daemonScanner) start() (int64, error) {
+	cursor, err := s.exp.index.Cursor()
+	if err != nil {
+		return 0, err
+	}
+
+	if cursor <= s.head {
+		return cursor, nil
+	}
+
+	//spent outputs are only kept for MaxReorgDepth blocks
+	if cursor-s.head > MaxReorgDepth {
+		return 0, fmt.Errorf("output index is at height %d, too far ahead of height %d, remove the index to rebuild it", cursor, s.head)
+	}
+
+	return s.head, s.exp.index.Rollback(s.head - 1)
+}
+
+func (s *daemonScanner) Scan(ctx context.Context) <-chan *Block {
+	ch := make(chan *Block)
+
+	go func() {
+		defer close(ch)
+
+		start, err := s.start()
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		if start < s.head {
+			log.Infof("indexing daemon outputs from height %d", start)
+		}
+
+		scanner := s.exp.client.Scan(start)
+		last := start - 1
+		for blk := range scanner.Scan(ctx) {
+			if blk.Height <= last {
+				//chain reorganization, the scanner restarts at the fork point
+				if err := s.exp.index.Rollback(blk.Height - 1); err != nil {
+					s.err = err
+					return
+				}
+			}
+
+			last = blk.Height
+			if err := s.exp.resolve(blk); err != nil {
+				s.err = fmt.Errorf("block (%d): %v", blk.Height, err)
+				return
+			}
+
+			if err := s.exp.index.Record(blk); err != nil {
+				s.err = fmt.Errorf("block (%d): index outputs: %v", blk.Height, err)
+				return
+			}
+
+			if blk.Height < s.head {
+				continue
+			}
+
+			select {
+			case ch <- blk:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+		}
+
+		s.err = scanner.Err()
+	}()
+
+	return ch
+}
@@ -1,6 +1,7 @@
 package reporter
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -19,3 +20,65 @@ func (e ExplorerError) Error() string {
 func (e ExplorerError) NoBlockFound() bool {
 	return e.Code == http.StatusBadRequest && strings.HasPrefix(e.Message, "no block found")
 }
+
+//noBlockFound returns the error the explorer returns for a height above the chain height
+func noBlockFound(h int64) error {
+	return ExplorerError{Code: http.StatusBadRequest, Message: fmt.Sprintf("no block found at height %d", h)}
+}
+
+//Retryable returns true if the explorer failed to serve a valid request, and the same request
+//may succeed later. Other errors are terminal, retrying them will always give the same error.
+func (e ExplorerError) Retryable() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return e.Code >= http.StatusInternalServerError
+}
+
+//DataError is returned when a block has invalid data, like a malformed unlock hash. It's terminal
+//since the explorer always returns the same block.
+type DataError struct {
+	//Context of the invalid data from the outer most, like the block, transaction and output
+	Context []string
+	Err     error
+}
+
+func (e DataError) Error() string {
+	return strings.Join(append(append([]string(nil), e.Context...), e.Err.Error()), ": ")
+}
+
+//Retryable always returns false
+func (e DataError) Retryable() bool {
+	return false
+}
+
+//dataError adds the context to a decoding error
+func dataError(err error, format string, args ...interface{}) error {
+	context := fmt.Sprintf(format, args...)
+	if derr, ok := err.(DataError); ok {
+		derr.Context = append([]string{context}, derr.Context...)
+		return derr
+	}
+
+	return DataError{Context: []string{context}, Err: err}
+}
+
+//IsRetryable returns true if a request to the explorer that failed with err can be retried. Transport
+//errors and timeouts are retryable (including a connection dropped while reading the response), while
+//a response that can't be decoded is terminal.
+func IsRetryable(err error) bool {
+	switch err := err.(type) {
+	case nil:
+		return false
+	case interface {
+		Retryable() bool
+	}:
+		return err.Retryable()
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	default:
+		return true
+	}
+}
@@ -6,88 +6,100 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 )
 
-//RawTransaction struct
-type RawTransaction struct {
-	Version int `jons:"version"`
-	Data    struct {
-		CoinOutputs []InputOutput `json:"coinoutputs"`
-		MinerFees   []json.Number `json:"minerfees"`
-	} `json:"data"`
-}
-
-type ConditionType int
+type FulfillmentType int
 
 const (
-	NilCondtion ConditionType = iota
-	UnlockHashCondition
-	AtomicSwapCondition
-	TimeLockCondition
-	MultiSignatureCondition
+	NilFulfillment FulfillmentType = iota
+	SingleSignatureFulfillment
+	AtomicSwapFulfillment
+	MultiSignatureFulfillment
 )
 
-type UnlockHashConditionData struct {
-	UnlockHash string `json:"unlockhash"`
+type AtomicSwapFulfillmentData struct {
+	PublicKey string `json:"publickey"`
+	Signature string `json:"signature"`
+	Secret    string `json:"secret"`
 }
 
-type AtomicSwapConditionData struct {
+//Fulfillment of a coin input
+type Fulfillment struct {
+	Type FulfillmentType `json:"type"`
+	Data json.RawMessage `json:"data"`
 }
 
-type TimeLockConditionData struct {
-	LockTime  int64     `json:"locktime"`
-	Condition Condition `jons:"condition"`
-}
+//AtomicSwapData returns the data of an atomic swap fulfillment
+func (f *Fulfillment) AtomicSwapData() (AtomicSwapFulfillmentData, error) {
+	var data AtomicSwapFulfillmentData
+	if f.Type != AtomicSwapFulfillment {
+		return data, fmt.Errorf("fulfillment type is %d, expecting %d", f.Type, AtomicSwapFulfillment)
+	}
 
-type MultiSignatureConditionData struct {
-	UnlockHashes          []string `json:"unlockhashes"`
-	MinimumSignatureCount int      `json:"minimumsignaturecount"`
+	err := json.Unmarshal(f.Data, &data)
+	return data, err
 }
 
-type Condition struct {
-	Type ConditionType   `json:"type"`
-	Data json.RawMessage `json:"data"`
+//Unlocker of a legacy (version 0) coin input, it has the condition of the spent output
+//and its fulfillment
+type Unlocker struct {
+	Type        FulfillmentType `json:"type"`
+	Condition   json.RawMessage `json:"condition"`
+	Fulfillment json.RawMessage `json:"fulfillment"`
 }
 
-func (c *Condition) UnlockHashData() UnlockHashConditionData {
-	if c.Type != UnlockHashCondition {
-		panic(fmt.Sprintf("condition type != %d", UnlockHashCondition))
-	}
+//CoinInput struct, also used for block stake inputs
+type CoinInput struct {
+	ParentID    string      `json:"parentid"`
+	Fulfillment Fulfillment `json:"fulfillment"`
+	//Unlocker is only set for legacy inputs, the fulfillment is set from it
+	Unlocker *Unlocker `json:"unlocker,omitempty"`
+}
 
-	var data UnlockHashConditionData
-	json.Unmarshal(c.Data, &data)
-	return data
+//InputOutput struct
+type InputOutput struct {
+	Value      Currency  `json:"value"`
+	UnlockHash string    `json:"unlockhash"`
+	Condition  Condition `json:"condition"`
 }
 
-func (c *Condition) TimeLockData() TimeLockConditionData {
-	if c.Type != TimeLockCondition {
-		panic(fmt.Sprintf("condition type != %d", UnlockHashCondition))
+//InputOutputs is a list of outputs, decoding errors have the index of the invalid output
+type InputOutputs []InputOutput
+
+//UnmarshalJSON decodes the outputs, and validates their unlock hashes
+func (l *InputOutputs) UnmarshalJSON(text []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(text, &raw); err != nil {
+		return err
 	}
 
-	var data TimeLockConditionData
-	json.Unmarshal(c.Data, &data)
-	return data
-}
+	outputs := make(InputOutputs, len(raw))
+	for i := range raw {
+		output := &outputs[i]
+		if err := json.Unmarshal(raw[i], output); err != nil {
+			return dataError(err, "output (%d)", i)
+		}
 
-func (c *Condition) MultiSignatureCondition() MultiSignatureConditionData {
-	if c.Type != MultiSignatureCondition {
-		panic(fmt.Sprintf("condition type != %d", UnlockHashCondition))
+		if len(output.UnlockHash) != 0 {
+			if err := ValidateUnlockHash(output.UnlockHash); err != nil {
+				return dataError(err, "output (%d)", i)
+			}
+		}
 	}
 
-	var data MultiSignatureConditionData
-	json.Unmarshal(c.Data, &data)
-	return data
-}
+	if raw == nil {
+		outputs = nil
+	}
 
-//InputOutput struct
-type InputOutput struct {
-	Value      json.Number `json:"value"`
-	UnlockHash string      `json:"unlockhash"`
-	Condition  Condition   `json:"condition"`
+	*l = outputs
+	return nil
 }
 
 //Transaction struct
@@ -96,36 +108,132 @@ type Transaction struct {
 	Height int64  `json:"height"`
 	Parent string `json:"parent"`
 
-	RawTransaction   RawTransaction `json:"rawtransaction"`
-	CoinInputOutputs []InputOutput  `json:"coininputoutputs"`
+	RawTransaction         RawTransaction `json:"rawtransaction"`
+	CoinInputOutputs       InputOutputs   `json:"coininputoutputs"`
+	CoinOutputIDs          []string       `json:"coinoutputids"`
+	CoinOutputUnlockHashes []string       `json:"coinoutputunlockhashes"`
+
+	BlockStakeInputOutputs       InputOutputs `json:"blockstakeinputoutputs"`
+	BlockStakeOutputIDs          []string     `json:"blockstakeoutputids"`
+	BlockStakeOutputUnlockHashes []string     `json:"blockstakeunlockhashes"`
+}
+
+//UnmarshalJSON decodes the transaction, decoding errors have the transaction id
+func (t *Transaction) UnmarshalJSON(text []byte) error {
+	type transaction Transaction
+	if err := json.Unmarshal(text, (*transaction)(t)); err != nil {
+		var body struct {
+			ID string `json:"id"`
+		}
+
+		json.Unmarshal(text, &body)
+		return dataError(err, "transaction '%s'", body.ID)
+	}
+
+	for _, hashes := range [][]string{t.CoinOutputUnlockHashes, t.BlockStakeOutputUnlockHashes} {
+		for i, hash := range hashes {
+			//outputs with a nil condition have no unlock hash
+			if len(hash) == 0 {
+				continue
+			}
+
+			if err := ValidateUnlockHash(hash); err != nil {
+				return dataError(err, "transaction '%s': output (%d)", t.ID, i)
+			}
+		}
+	}
+
+	return nil
 }
 
 //Block struct
 type Block struct {
-	Transactions []Transaction `json:"transactions"`
-	Height       int64         `json:"height"`
+	ID             string        `json:"blockid"`
+	Transactions   []Transaction `json:"transactions"`
+	Height         int64         `json:"height"`
+	MinerPayoutIDs []string      `json:"minerpayoutids"`
 
 	RawBlock struct {
-		Timestamp    int64         `json:"timestamp"`
-		MinerPayouts []InputOutput `json:"minerpayouts"`
+		ParentID     string       `json:"parentid"`
+		Timestamp    int64        `json:"timestamp"`
+		MinerPayouts InputOutputs `json:"minerpayouts"`
 	} `json:"rawblock"`
+
+	//Raw is the block as returned by the explorer, it's kept to archive the block without losing
+	//the fields we don't decode
+	Raw json.RawMessage `json:"-"`
 }
 
 //Explorer an explorer client interface
 type Explorer interface {
-	GetBlock(h int64) (*Block, error)
+	//GetBlock returns the block at height h, the retries stop when ctx is done
+	GetBlock(ctx context.Context, h int64) (*Block, error)
+	//ChainHeight returns the height of the last block of the chain
+	ChainHeight(ctx context.Context) (int64, error)
 	Scan(h int64) Scanner
 }
 
 //Scanner as explorer scanner
+//
+//When the scanner detects a chain reorganization it restarts from the fork point, so the
+//next block it returns has a height lower or equal to a previously returned block. All blocks
+//recorded from that height on belong to the orphaned branch and must be rolled back.
 type Scanner interface {
 	Scan(ctx context.Context) <-chan *Block
 	Err() error
 }
 
+//ExplorerOptions configures the explorer client
+type ExplorerOptions struct {
+	//Window is the number of blocks the scanner fetches concurrently ahead of the
+	//block it returns, a window of 1 or less fetches one block at a time
+	Window int
+	//Timeout of a single request, zero means no timeout
+	Timeout time.Duration
+	//Retries is the number of times a request that failed with a retryable error (see IsRetryable)
+	//is retried, a negative value retries forever
+	Retries int
+	//Backoff is the wait time before the first retry, it's doubled on each retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	//UserAgent sent with the requests, rivine daemons only accept agents containing Rivine-Agent
+	UserAgent string
+	//Password of the explorer API, if it requires authentication
+	Password string
+	//PollInterval is how often the chain height is checked for new blocks once the
+	//scanner reached the chain tip, defaults to DefaultPollInterval
+	PollInterval time.Duration
+	//Raw keeps the json of the blocks in Block.Raw, it's only needed to archive them
+	Raw bool
+}
+
 //NewExplorer creates a new explorer client
-func NewExplorer(u string) (Explorer, error) {
-	cl := &http.Client{}
+func NewExplorer(u string, opts ExplorerOptions) (Explorer, error) {
+	if opts.Window < 1 {
+		opts.Window = 1
+	}
+
+	if len(opts.UserAgent) == 0 {
+		opts.UserAgent = DefaultUserAgent
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = opts.Backoff
+	}
+
+	cl := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			//keep a connection for each concurrent block request
+			MaxIdleConnsPerHost: opts.Window,
+		},
+	}
+
 	uri, err := url.Parse(u)
 	if err != nil {
 		return nil, err
@@ -134,16 +242,31 @@ func NewExplorer(u string) (Explorer, error) {
 		return nil, fmt.Errorf("invalid url scheme")
 	}
 
-	return &httpExplorer{u: uri, cl: cl}, nil
+	return &httpExplorer{u: uri, cl: cl, opts: opts}, nil
 }
 
 const (
 	blockEndpoint = "explorer/blocks/"
+	chainEndpoint = "explorer"
+
+	//DefaultPollInterval is the default interval of checking for new blocks at the chain tip
+	DefaultPollInterval = 5 * time.Second
+
+	//DefaultUserAgent is the user agent of the explorer requests
+	DefaultUserAgent = "Rivine-Agent"
+
+	//MaxReorgDepth is the max number of blocks that can be rolled back on a chain reorganization
+	MaxReorgDepth = 100
+
+	//parentRetries is the number of times a block whose parent doesn't match is fetched again, when
+	//the explorer still has the parent on its main chain
+	parentRetries = 5
 )
 
 type httpExplorer struct {
-	cl *http.Client
-	u  *url.URL
+	cl   *http.Client
+	u    *url.URL
+	opts ExplorerOptions
 }
 
 func (e *httpExplorer) errorFromResponse(r *http.Response) error {
@@ -154,62 +277,284 @@ func (e *httpExplorer) errorFromResponse(r *http.Response) error {
 
 	var o ExplorerError
 	if err := json.Unmarshal(body, &o); err != nil {
-		return fmt.Errorf("failed to parse error message '%v': %v", string(body), err)
+		//proxies in front of the explorer don't answer with json, keep the status code
+		//so the error can still be classified
+		o.Message = strings.TrimSpace(string(body))
 	}
 
 	o.Code = r.StatusCode
 	return o
 }
 
-func (e *httpExplorer) request(method, endpoint string, body io.Reader) (*http.Request, error) {
+func (e *httpExplorer) request(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
 	url := e.u.String() + "/" + endpoint
-	request, err := http.NewRequest(method, url, nil)
+	request, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	//todo: we need to make the agent customizable
-	request.Header.Set("user-agent", "Rivine-Agent")
+	request = request.WithContext(ctx)
+
+	request.Header.Set("user-agent", e.opts.UserAgent)
+	if len(e.opts.Password) != 0 {
+		//rivine daemons use basic auth with an empty user name
+		request.SetBasicAuth("", e.opts.Password)
+	}
 
 	return request, nil
 }
 
-func (e *httpExplorer) GetBlock(h int64) (*Block, error) {
-	request, err := e.request(http.MethodGet, path.Join(blockEndpoint, fmt.Sprint(h)), nil)
-	if err != nil {
-		return nil, err
+//backoff returns the wait time before the given retry, with a random jitter so concurrent
+//requests don't retry all at the same time
+func backoff(opts ExplorerOptions, retry int) time.Duration {
+	//the wait time is clamped to MaxBackoff before it's doubled, so it can't overflow
+	d := opts.Backoff
+	for i := 0; i < retry && d > 0 && d < opts.MaxBackoff; i++ {
+		if d > opts.MaxBackoff/2 {
+			d = opts.MaxBackoff
+		} else {
+			d *= 2
+		}
 	}
 
-	response, err := e.cl.Do(request)
-	if err != nil {
-		return nil, err
+	if d > opts.MaxBackoff {
+		d = opts.MaxBackoff
 	}
 
-	defer response.Body.Close()
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+//retry calls fn until it succeeds, fails with a terminal error, runs out of retries, or ctx is done
+func retry(ctx context.Context, opts ExplorerOptions, what string, fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		if !IsRetryable(err) || (opts.Retries >= 0 && retry >= opts.Retries) || ctx.Err() != nil {
+			return err
+		}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, e.errorFromResponse(response)
+		d := backoff(opts, retry)
+		log.Warningf("%s failed (%s), retrying in %s", what, err, d)
+
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return err
+		}
 	}
+}
+
+//get requests the endpoint, and decodes the response body into v with retries
+func (e *httpExplorer) get(ctx context.Context, endpoint string, v interface{}) error {
+	return retry(ctx, e.opts, fmt.Sprintf("get %s", endpoint), func() error {
+		request, err := e.request(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := e.cl.Do(request)
+		if err != nil {
+			return err
+		}
 
-	enc := json.NewDecoder(response.Body)
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return e.errorFromResponse(response)
+		}
+
+		return json.NewDecoder(response.Body).Decode(v)
+	})
+}
+
+func (e *httpExplorer) ChainHeight(ctx context.Context) (int64, error) {
 	var body struct {
-		Block Block `json:"block"`
+		Height int64 `json:"height"`
 	}
-	if err := enc.Decode(&body); err != nil {
+
+	if err := e.get(ctx, chainEndpoint, &body); err != nil {
+		return 0, err
+	}
+
+	return body.Height, nil
+}
+
+func (e *httpExplorer) GetBlock(ctx context.Context, h int64) (*Block, error) {
+	var body struct {
+		Block json.RawMessage `json:"block"`
+	}
+
+	if err := e.get(ctx, path.Join(blockEndpoint, fmt.Sprint(h)), &body); err != nil {
 		return nil, err
 	}
 
-	return &body.Block, nil
+	var blk Block
+	if err := json.Unmarshal(body.Block, &blk); err != nil {
+		return nil, dataError(err, "block (%d)", h)
+	}
+
+	if e.opts.Raw {
+		blk.Raw = body.Block
+	}
+
+	return &blk, nil
 }
 
 func (e *httpExplorer) Scan(head int64) Scanner {
-	return &explorerScanner{exp: e, head: head}
+	return newScanner(e, head, e.opts)
+}
+
+//newScanner creates a scanner that gets the blocks from exp starting at head
+func newScanner(exp Explorer, head int64, opts ExplorerOptions) *explorerScanner {
+	return &explorerScanner{
+		exp:     exp,
+		head:    head,
+		ids:     make(map[int64]string),
+		opts:    opts,
+		window:  opts.Window,
+		poll:    opts.PollInterval,
+		pending: make(map[int64]*prefetch),
+		missing: math.MaxInt64,
+	}
 }
 
 type explorerScanner struct {
 	exp  Explorer
 	head int64
 	err  error
+
+	//ids of the last returned blocks by height
+	ids map[int64]string
+	//mismatches is the number of times in a row the block at head had another parent
+	mismatches int
+
+	opts    ExplorerOptions
+	window  int
+	poll    time.Duration
+	pending map[int64]*prefetch
+	//missing is the lowest height the explorer didn't have a block for, nothing is
+	//fetched ahead of it since the chain didn't reach it yet
+	missing int64
+}
+
+//prefetch is a block request running in the background, cancel aborts the request
+type prefetch struct {
+	blk    *Block
+	err    error
+	ahead  bool
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+//fetch returns the block at height h, and starts fetching the blocks of the window after it. At most
+//window requests are pending at any time, and their blocks are returned strictly in order.
+func (s *explorerScanner) fetch(ctx context.Context, h int64) (*Block, error) {
+	for n := h; n < h+int64(s.window) && (n == h || n < s.missing); n++ {
+		if _, ok := s.pending[n]; ok {
+			continue
+		}
+
+		c, cancel := context.WithCancel(ctx)
+		p := &prefetch{ahead: n != h, done: make(chan struct{}), cancel: cancel}
+		s.pending[n] = p
+		go func(n int64, p *prefetch) {
+			p.blk, p.err = s.exp.GetBlock(c, n)
+			close(p.done)
+		}(n, p)
+	}
+
+	p := s.pending[h]
+	delete(s.pending, h)
+	defer p.cancel()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if eerr, ok := p.err.(ExplorerError); ok && eerr.NoBlockFound() {
+		//the blocks fetched ahead are not there either
+		s.missing = h
+		s.reset()
+	} else if p.err != nil && p.ahead {
+		//the request failed while we were busy with the blocks before it, give it another try
+		//unless it failed because the scan is over
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return s.exp.GetBlock(ctx, h)
+	} else if p.err == nil && h >= s.missing {
+		//we are following the chain tip, fetch the next blocks one by one
+		s.missing = h + 1
+	}
+
+	return p.blk, p.err
+}
+
+//wait polls the explorer chain height until the chain reaches the scanner head
+func (s *explorerScanner) wait(ctx context.Context) error {
+	for {
+		select {
+		case <-time.After(s.poll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		height, err := s.exp.ChainHeight(ctx)
+		if err != nil && !IsRetryable(err) {
+			return err
+		} else if err != nil {
+			log.Warningf("get chain height: %s", err)
+			continue
+		}
+
+		if height >= s.head {
+			//all the blocks up to the chain tip can be fetched ahead
+			s.missing = height + 1
+			return nil
+		}
+	}
+}
+
+//reset cancels the pending requests, their results are ignored
+func (s *explorerScanner) reset() {
+	for _, p := range s.pending {
+		p.cancel()
+	}
+
+	s.pending = make(map[int64]*prefetch)
+}
+
+func (s *explorerScanner) remember(blk *Block) {
+	s.ids[blk.Height] = blk.ID
+	delete(s.ids, blk.Height-MaxReorgDepth)
+}
+
+//fork walks back from height h until it finds a returned block that is still on the
+//explorer main chain, and returns its height
+func (s *explorerScanner) fork(ctx context.Context, h int64) (int64, error) {
+	for ; ; h-- {
+		id, ok := s.ids[h]
+		if !ok {
+			return 0, fmt.Errorf("chain reorganization deeper than %d blocks", MaxReorgDepth)
+		}
+
+		blk, err := s.exp.GetBlock(ctx, h)
+		if eerr, ok := err.(ExplorerError); ok && eerr.NoBlockFound() {
+			//the new branch is still shorter than the orphaned one
+		} else if err != nil {
+			return 0, err
+		} else if blk.ID == id {
+			return h, nil
+		}
+
+		delete(s.ids, h)
+	}
 }
 
 func (s *explorerScanner) Err() error {
@@ -221,19 +566,31 @@ func (s *explorerScanner) Scan(ctx context.Context) <-chan *Block {
 
 	go func() {
 		defer close(ch)
+		defer s.reset()
+
+		if s.head > 0 {
+			//we need the parent of the first block to detect a reorganization
+			blk, err := s.exp.GetBlock(ctx, s.head-1)
+			if err != nil {
+				s.err = err
+				return
+			}
+
+			s.remember(blk)
+		}
 
 		for {
-			blk, err := s.exp.GetBlock(s.head)
+			blk, err := s.fetch(ctx, s.head)
 			switch err := err.(type) {
 			case ExplorerError:
 				if err.NoBlockFound() {
-					select {
-					case <-time.After(1 * time.Minute):
-						continue
-					case <-ctx.Done():
+					//we reached the chain tip
+					if err := s.wait(ctx); err != nil {
 						s.err = err
 						return
 					}
+
+					continue
 				}
 			}
 
@@ -242,6 +599,42 @@ func (s *explorerScanner) Scan(ctx context.Context) <-chan *Block {
 				return
 			}
 
+			if parent, ok := s.ids[s.head-1]; ok && parent != blk.RawBlock.ParentID {
+				fork, err := s.fork(ctx, s.head-1)
+				if err != nil {
+					s.err = err
+					return
+				} else if fork == s.head-1 {
+					//the parent is still on the explorer main chain, the block is from a branch the explorer
+					//switched away from while we fetched it, fetch it again before giving up
+					mismatch := fmt.Errorf("block (%d) parent '%s' doesn't match block id '%s'", s.head, blk.RawBlock.ParentID, parent)
+					if s.mismatches >= parentRetries {
+						s.err = mismatch
+						return
+					}
+
+					d := backoff(s.opts, s.mismatches)
+					s.mismatches++
+					log.Warningf("%s, fetching it again in %s", mismatch, d)
+					s.reset()
+
+					select {
+					case <-time.After(d):
+					case <-ctx.Done():
+						s.err = ctx.Err()
+						return
+					}
+
+					continue
+				}
+
+				log.Warningf("chain reorganization detected at height %d, restarting from height %d", s.head, fork+1)
+				//blocks fetched ahead may belong to the orphaned branch
+				s.reset()
+				s.head = fork + 1
+				continue
+			}
+
 			select {
 			case ch <- blk:
 			case <-ctx.Done():
@@ -249,6 +642,8 @@ func (s *explorerScanner) Scan(ctx context.Context) <-chan *Block {
 				return
 			}
 
+			s.remember(blk)
+			s.mismatches = 0
 			s.head++
 		}
 	}()
@@ -0,0 +1,67 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//TestBlockDataError checks the context of a malformed block error is the one documented in the README
+func TestBlockDataError(t *testing.T) {
+	block := fmt.Sprintf(`{"block": {"blockid": "b4", "height": 4, "rawblock": {"timestamp": 1500000000}, "transactions": [{
+		"id": "t1", "height": 4,
+		"rawtransaction": {"version": 1, "data": {"coinoutputs": [{"value": "10", "condition": {"type": 1, "data": {"unlockhash": "%s"}}}]}}
+	}]}}`, "01ab")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, block)
+	}))
+	defer server.Close()
+
+	explorer, err := NewExplorer(server.URL, ExplorerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = explorer.GetBlock(context.Background(), 4)
+	if _, ok := err.(DataError); !ok {
+		t.Fatalf("got error %v, expecting a data error", err)
+	}
+
+	expected := "block (4): transaction 't1': transaction version 1: output (0): condition type 1: invalid unlock hash '01ab', expecting 78 hex characters"
+	if err.Error() != expected {
+		t.Errorf("got error %q, expecting %q", err, expected)
+	}
+
+	if IsRetryable(err) {
+		t.Error("a data error must not be retried")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		opts     ExplorerOptions
+		retry    int
+		expected time.Duration
+	}{
+		{ExplorerOptions{Backoff: time.Second, MaxBackoff: time.Minute}, 0, time.Second},
+		{ExplorerOptions{Backoff: time.Second, MaxBackoff: time.Minute}, 3, 8 * time.Second},
+		{ExplorerOptions{Backoff: time.Second, MaxBackoff: time.Minute}, 6, time.Minute},
+		//shifting the backoff by the retry count would overflow
+		{ExplorerOptions{Backoff: time.Hour, MaxBackoff: 24 * time.Hour}, 40, 24 * time.Hour},
+		{ExplorerOptions{Backoff: time.Second, MaxBackoff: math.MaxInt64}, 1000, math.MaxInt64},
+		{ExplorerOptions{}, 1000, 0},
+	}
+
+	for _, c := range cases {
+		//the wait time has a random jitter of up to half of it
+		d := backoff(c.opts, c.retry)
+		if d < c.expected/2 || d > c.expected {
+			t.Errorf("got backoff %s of retry %d with %+v, expecting %s to %s", d, c.retry, c.opts, c.expected/2, c.expected)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package reporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+//HistoryType type of a history entry
+type HistoryType string
+
+const (
+	//HistoryMinerPayout entry of a block miner payout
+	HistoryMinerPayout HistoryType = "minerpayout"
+	//HistoryTransaction entry of a transaction
+	HistoryTransaction HistoryType = "transaction"
+)
+
+//HistoryEntry is the change of an address balance by a single transaction or block miner payouts
+type HistoryEntry struct {
+	Height    int64
+	Timestamp int64
+	Type      HistoryType
+	//ID of the transaction, or of the block in case of miner payouts
+	ID string
+	//Value is positive for incoming, and negative for outgoing tokens
+	Value Currency
+}
+
+//Direction returns in, out or self if the tokens were sent back to the same address
+func (e *HistoryEntry) Direction() string {
+	switch e.Value.Sign() {
+	case 1:
+		return "in"
+	case -1:
+		return "out"
+	default:
+		return "self"
+	}
+}
+
+//TimelinePoint is the balance of an address after the block at Height
+type TimelinePoint struct {
+	Height    int64
+	Timestamp int64
+	Balance   Currency
+}
+
+//history records the deltas of a single transaction (or the miner payouts) in the address history
+//and adds them to the block addresses
+func (r *AddressRecorder) history(tx querier, blk *Block, addresses, deltas Addresses, typ HistoryType, id string) error {
+	for add, delta := range deltas {
+		_, err := tx.Exec(
+			"insert into history (address, height, timestamp, type, id, value) values (?, ?, ?, ?, ?, ?);",
+			add, blk.Height, blk.RawBlock.Timestamp, typ, id, delta.String(),
+		)
+		if err != nil {
+			return err
+		}
+
+		addresses[add] = addresses[add].Add(delta)
+	}
+
+	return nil
+}
+
+//History returns the transactions that changed the balance of this address, most recent first
+func (r *AddressRecorder) History(address string, page, size int) ([]HistoryEntry, error) {
+	rows, err := r.db.Query(
+		"select height, timestamp, type, id, value from history where address = ? order by height desc, rowid desc limit ? offset ?;",
+		address, size, page*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var value string
+		if err := rows.Scan(&entry.Height, &entry.Timestamp, &entry.Type, &entry.ID, &value); err != nil {
+			return nil, err
+		}
+
+		if entry.Value, err = ParseCurrency(value); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+//Timeline returns the balance of this address after each block that changed it, ordered by height
+func (r *AddressRecorder) Timeline(address string, page, size int) ([]TimelinePoint, error) {
+	rows, err := r.db.Query(
+		"select height, timestamp, value from timeline where address = ? order by height limit ? offset ?;",
+		address, size, page*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var points []TimelinePoint
+	for rows.Next() {
+		var point TimelinePoint
+		var value string
+		if err := rows.Scan(&point.Height, &point.Timestamp, &value); err != nil {
+			return nil, err
+		}
+
+		if point.Balance, err = parseSortable(value); err != nil {
+			return nil, err
+		}
+
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+//BalanceAt returns the balance of this address after the block at height h
+func (r *AddressRecorder) BalanceAt(address string, h int64) (Currency, error) {
+	return r.balanceAt(r.db, address, h)
+}
+
+func (r *AddressRecorder) balanceAt(q querier, address string, h int64) (Currency, error) {
+	row := q.QueryRow(
+		"select value from timeline where address = ? and height <= ? order by height desc limit 1;",
+		address, h,
+	)
+
+	var value string
+	if err := row.Scan(&value); err == sql.ErrNoRows {
+		return Currency{}, nil
+	} else if err != nil {
+		return Currency{}, err
+	}
+
+	return parseSortable(value)
+}
+
+//HeightAt returns the height of the last recorded block with a timestamp before or at t
+func (r *AddressRecorder) HeightAt(t time.Time) (int64, error) {
+	row := r.db.QueryRow("select max(height) from block where timestamp <= ?;", t.Unix())
+
+	var height sql.NullInt64
+	if err := row.Scan(&height); err != nil {
+		return 0, err
+	}
+
+	if !height.Valid {
+		return 0, fmt.Errorf("no block recorded before %s", t.Format(time.RFC3339))
+	}
+
+	return height.Int64, nil
+}
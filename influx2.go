@@ -0,0 +1,230 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	influxdb "github.com/influxdata/influxdb/client/v2"
+)
+
+//influxV2Client is a client of the influxdb 2.x API. The points are written to the bucket with the write
+//API, and the influxql queries run on the 1.x compatibility API, which needs a database mapping (dbrp) of
+//the bucket. The bucket and its mapping are created if they don't exist.
+type influxV2Client struct {
+	addr   string
+	org    string
+	bucket string
+	token  string
+	client *http.Client
+}
+
+func newInfluxV2Client(addr, bucket string, opts InfluxOptions) (*influxV2Client, error) {
+	if len(opts.Org) == 0 {
+		return nil, fmt.Errorf("missing organization of the influxdb 2.x bucket")
+	}
+
+	c := &influxV2Client{
+		addr:   addr,
+		org:    opts.Org,
+		bucket: bucket,
+		token:  opts.Token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	return c, c.init()
+}
+
+//do sends a request to the API, body is either a reader or an object that is sent as json, and the
+//json response is decoded into result if not nil
+func (c *influxV2Client) do(method, p string, params url.Values, body interface{}, result interface{}) error {
+	var reader io.Reader
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = body
+		contentType = "text/plain; charset=utf-8"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.addr + p
+	if len(params) != 0 {
+		u += "?" + params.Encode()
+	}
+
+	request, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Token "+c.token)
+	if reader != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		err := influxError(response)
+		err.Message = fmt.Sprintf("%s %s: %s", method, p, err.Message)
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(response.Body)
+	dec.UseNumber()
+	return dec.Decode(result)
+}
+
+//init creates the bucket and its database mapping if they don't exist
+func (c *influxV2Client) init() error {
+	var orgs struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}
+
+	if err := c.do("GET", "/api/v2/orgs", url.Values{"org": {c.org}}, nil, &orgs); err != nil {
+		return err
+	}
+
+	if len(orgs.Orgs) == 0 {
+		return fmt.Errorf("unknown influxdb organization '%s'", c.org)
+	}
+
+	orgID := orgs.Orgs[0].ID
+
+	var buckets struct {
+		Buckets []struct {
+			ID string `json:"id"`
+		} `json:"buckets"`
+	}
+
+	if err := c.do("GET", "/api/v2/buckets", url.Values{"orgID": {orgID}, "name": {c.bucket}}, nil, &buckets); err != nil {
+		return err
+	}
+
+	var bucketID string
+	if len(buckets.Buckets) != 0 {
+		bucketID = buckets.Buckets[0].ID
+	} else {
+		var bucket struct {
+			ID string `json:"id"`
+		}
+
+		body := map[string]interface{}{"orgID": orgID, "name": c.bucket, "retentionRules": []interface{}{}}
+		if err := c.do("POST", "/api/v2/buckets", nil, body, &bucket); err != nil {
+			return err
+		}
+
+		bucketID = bucket.ID
+	}
+
+	var dbrps struct {
+		Content []struct {
+			ID string `json:"id"`
+		} `json:"content"`
+	}
+
+	if err := c.do("GET", "/api/v2/dbrps", url.Values{"orgID": {orgID}, "bucketID": {bucketID}}, nil, &dbrps); err != nil {
+		return err
+	}
+
+	if len(dbrps.Content) != 0 {
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"orgID":            orgID,
+		"bucketID":         bucketID,
+		"database":         c.bucket,
+		"retention_policy": "autogen",
+		"default":          true,
+	}
+
+	return c.do("POST", "/api/v2/dbrps", nil, body, nil)
+}
+
+func (c *influxV2Client) Database() string {
+	return c.bucket
+}
+
+func (c *influxV2Client) Write(bp influxdb.BatchPoints) error {
+	//the points are formatted with the 1.x precision of the batch, and written with the same 2.x precision
+	//which has no minutes and hours
+	format, precision := "n", "ns"
+	switch bp.Precision() {
+	case "u", "us":
+		format, precision = "u", "us"
+	case "ms":
+		format, precision = "ms", "ms"
+	case "s", "m", "h":
+		format, precision = "s", "s"
+	}
+
+	var b bytes.Buffer
+	for _, point := range bp.Points() {
+		b.WriteString(point.PrecisionString(format))
+		b.WriteByte('\n')
+	}
+
+	params := url.Values{"org": {c.org}, "bucket": {c.bucket}, "precision": {precision}}
+	return c.do("POST", "/api/v2/write", params, &b, nil)
+}
+
+func (c *influxV2Client) Query(q influxdb.Query) (*influxdb.Response, error) {
+	params := url.Values{"db": {q.Database}, "q": {q.Command}}
+	if len(q.Precision) != 0 {
+		params.Set("epoch", q.Precision)
+	}
+
+	var response influxdb.Response
+	if err := c.do("POST", "/query", params, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+//Delete deletes the time ranges with the delete API, one request per range. The range of the API
+//ends 1ns after the last point, the only point that can be there is the next transaction of the same
+//block which is deleted as well.
+func (c *influxV2Client) Delete(series string, ranges []TimeRange) error {
+	params := url.Values{"org": {c.org}, "bucket": {c.bucket}}
+	for _, r := range ranges {
+		body := map[string]string{
+			"start":     time.Unix(0, r.Start).UTC().Format(time.RFC3339Nano),
+			"stop":      time.Unix(0, r.Stop+1).UTC().Format(time.RFC3339Nano),
+			"predicate": fmt.Sprintf(`_measurement="%s"`, series),
+		}
+
+		if err := c.do("POST", "/api/v2/delete", params, body, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *influxV2Client) Close() error {
+	return nil
+}
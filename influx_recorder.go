@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
+	"math/big"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,76 +17,73 @@ const (
 	InfluxDatabaseName   = "rivine"
 	InfluxPointBatchSize = 100
 	InfluxSeriesName     = "transaction"
-)
-
-const (
-	//LastHour period
-	LastHour Period = "1h"
-	//LastDay period
-	LastDay Period = "1d"
-	//LastWeek period
-	LastWeek Period = "1w"
-	//LastMonth period
-	LastMonth Period = "4w"
+	//InfluxBlockSeriesName has a point per recorded block, it's used to resume from the last recorded block
+	InfluxBlockSeriesName = "block"
+	//influxTotalField is the block field with the total tokens on the chain after the block
+	influxTotalField = "total"
 )
 
 var (
 	NoValueError = fmt.Errorf("no value")
-
-	periodP      = regexp.MustCompile(`^\d+(\w{1,2})$`)
-	periodSuffix = map[string]struct{}{
-		"u":  struct{}{},
-		"ms": struct{}{},
-		"s":  struct{}{},
-		"m":  struct{}{},
-		"h":  struct{}{},
-		"d":  struct{}{},
-		"w":  struct{}{},
-	}
 )
 
-//Period look back period
-type Period string
-
-//Valid validate period string
-func (p Period) Valid() error {
-	m := periodP.FindStringSubmatch(string(p))
-	if len(m) == 0 {
-		return fmt.Errorf("invalid period format, expecting <number><suffix>")
-	}
-
-	if _, ok := periodSuffix[m[1]]; !ok {
-		return fmt.Errorf("invalid period suffix, where suffix is one of (u, ms, s, m, h, d, w)")
-	}
+//fields returns the influx fields of the block values
+func (v *blockValue) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	amountField(fields, "reward", v.Reward)
+	amountField(fields, "minted", v.Minted)
+	amountField(fields, "burned", v.Burned)
+	return fields
+}
 
-	return nil
+//exactField returns the name of the field with the exact amount of hastings of an amount field
+func exactField(name string) string {
+	return name + "_hastings"
 }
 
-type txnValue struct {
-	Output          float64
-	Input           float64
-	Fees            float64
-	InputAddresses  int
-	OutputAddresses int
+//amountField sets the amount field to a float number of hastings, which is only approximate but can be
+//aggregated by influxdb (and has the type of the amounts recorded by older versions), and its exact field
+//to the decimal amount of hastings as a string
+func amountField(fields map[string]interface{}, name string, value Currency) {
+	approx, _ := new(big.Float).SetInt(value.Big()).Float64()
+	fields[name] = approx
+	fields[exactField(name)] = value.String()
 }
 
+//InfluxRecorder records the time series in influxdb. With a spool, the batches that fail to be written are
+//spooled and replayed in order once influxdb is reachable again, instead of failing the recording.
 type InfluxRecorder struct {
-	cl            *InfluxClient
+	cl            InfluxClient
+	chain         *Chain
+	spool         *Spool
 	batchSize     int
 	batch         influxdb.BatchPoints
 	flushInterval time.Duration
 
+	//backfill are the recorded blocks without issuance, up to the recorded height the other blocks are
+	//not recorded again
+	backfill []heightRange
+	recorded int64
+	//total tokens after the last recorded block, it's loaded by Cursor and Rollback
+	total Currency
+
 	cancel context.CancelFunc
 	m      sync.Mutex
 }
 
-//NewInfluxRecorder creates a new reporter for influxdb
-func NewInfluxRecorder(u string, batchSize int, flushInterval time.Duration) (*InfluxRecorder, error) {
-	cl, err := newInfluxDB(u)
+//heightRange is a range of block heights, from and to included
+type heightRange struct {
+	from, to int64
+}
+
+//NewInfluxRecorder creates a new reporter for influxdb, the block issuance is checked against the
+//chain profile if given, and the failed writes are spooled to spool if given
+func NewInfluxRecorder(u string, opts InfluxOptions, chain *Chain, spool *Spool, batchSize int, flushInterval time.Duration) (*InfluxRecorder, error) {
+	cl, err := newInfluxDB(u, opts)
 	if err != nil {
 		return nil, err
 	}
-	reporter := &InfluxRecorder{cl: cl, batchSize: batchSize, flushInterval: flushInterval}
+	reporter := &InfluxRecorder{cl: cl, chain: chain, spool: spool, batchSize: batchSize, flushInterval: flushInterval}
 	return reporter, reporter.init()
 }
 
@@ -97,8 +96,9 @@ func (r *InfluxRecorder) init() error {
 		for {
 			select {
 			case <-time.After(d):
-				fmt.Println("timedflush")
-				r.flush()
+				if err := r.flush(); err != nil {
+					log.Errorf("influxdb flush error: %s", err)
+				}
 			case <-ctx.Done():
 				return
 			}
@@ -108,78 +108,92 @@ func (r *InfluxRecorder) init() error {
 	return nil
 }
 
-func (r *InfluxRecorder) aggregate(txn *Transaction) (txnValue, error) {
-	var values txnValue
+//fields returns the influx fields of the transaction values
+func (v *txnValue) fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"input_addresses":  v.InputAddresses,
+		"output_addresses": v.OutputAddresses,
+	}
 
-	//updating transaction fees
-	for _, fee := range txn.RawTransaction.Data.MinerFees {
-		value, err := fee.Float64()
-		if err != nil {
-			return values, err
-		}
+	amountField(fields, "input", v.Input)
+	amountField(fields, "output", v.Output)
+	amountField(fields, "fees", v.Fees)
+	return fields
+}
 
-		values.Fees += value
+//blockPoints returns the influx points of a block, a point per transaction and the block point. If total
+//is given, the block issuance is added to it and the block point has the total tokens after the block.
+func blockPoints(blk *Block, chain *Chain, total *Currency) ([]*influxdb.Point, error) {
+	var points []*influxdb.Point
+
+	ts := time.Unix(blk.RawBlock.Timestamp, 0)
+	values, issuance, err := blockValues(blk, chain)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, output := range txn.RawTransaction.Data.CoinOutputs {
-		values.OutputAddresses++
-		value, err := output.Value.Float64()
+	for i, value := range values {
+		fields := value.fields()
+		fields["height"] = blk.Height
+		//points with the same time overwrite each others, so each transaction is offset by its index in
+		//nanoseconds which also makes recording the same block twice idempotent. Block timestamps are in
+		//seconds, so the offset never reaches the next second.
+		point, err := influxdb.NewPoint(InfluxSeriesName, nil, fields, ts.Add(time.Duration(i)))
+
 		if err != nil {
-			return values, err
+			return nil, err
 		}
-		values.Output += value
+		points = append(points, point)
 	}
 
-	for _, input := range txn.CoinInputOutputs {
-		values.InputAddresses++
-		value, err := input.Value.Float64()
-		if err != nil {
-			return values, err
-		}
-		values.Input += value
+	fields := issuance.fields()
+	fields["height"] = blk.Height
+	fields["transactions"] = len(blk.Transactions)
+	if total != nil {
+		*total = total.Add(issuance.Total())
+		amountField(fields, influxTotalField, *total)
+	}
+
+	//the block point is in the same batch as the transactions points, so the cursor never
+	//gets ahead of the recorded data
+	point, err := influxdb.NewPoint(InfluxBlockSeriesName, nil, fields, ts)
+	if err != nil {
+		return nil, err
 	}
 
-	return values, nil
+	return append(points, point), nil
 }
 
 func (r *InfluxRecorder) Record(blk *Block) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	if r.batch == nil {
-		var err error
-		r.batch, err = influxdb.NewBatchPoints(influxdb.BatchPointsConfig{Database: r.cl.Database})
+	if r.skip(blk.Height) {
+		//the block is recorded with its total already, which is the running total with its issuance
+		_, issuance, err := blockValues(blk, nil)
 		if err != nil {
 			return err
 		}
+
+		r.total = r.total.Add(issuance.Total())
+		return nil
 	}
 
-	ts := time.Unix(blk.RawBlock.Timestamp, 0)
-	for _, txn := range blk.Transactions {
-		values, err := r.aggregate(&txn)
+	if r.batch == nil {
+		var err error
+		r.batch, err = influxdb.NewBatchPoints(influxdb.BatchPointsConfig{Database: r.cl.Database()})
 		if err != nil {
 			return err
 		}
+	}
 
-		point, err := influxdb.NewPoint(
-			InfluxSeriesName, nil,
-			map[string]interface{}{
-				"input":            values.Input,
-				"output":           values.Output,
-				"input_addresses":  values.InputAddresses,
-				"output_addresses": values.OutputAddresses,
-				"fees":             values.Fees,
-				"height":           blk.Height,
-			},
-			ts,
-		)
-
-		if err != nil {
-			return err
-		}
-		r.batch.AddPoint(point)
+	points, err := blockPoints(blk, r.chain, &r.total)
+	if err != nil {
+		return err
 	}
 
+	r.batch.AddPoints(points)
+
 	if len(r.batch.Points()) >= r.batchSize {
 		//we already have the lock, then just call _flush
 		return r._flush()
@@ -188,35 +202,175 @@ func (r *InfluxRecorder) Record(blk *Block) error {
 	return nil
 }
 
+//skip returns true if the block at height h is recorded already and doesn't need a backfill
+func (r *InfluxRecorder) skip(h int64) bool {
+	if len(r.backfill) == 0 || h > r.recorded {
+		return false
+	}
+
+	for _, missing := range r.backfill {
+		if h >= missing.from && h <= missing.to {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (r *InfluxRecorder) flush() error {
 	r.m.Lock()
 	defer r.m.Unlock()
 	return r._flush()
 }
 
+//_flush writes the points in buffer, after the spooled points. If the write fails the points are spooled,
+//and the error is only returned if the spool is still not empty when there are no points in buffer
 func (r *InfluxRecorder) _flush() error {
-	if r.batch != nil && len(r.batch.Points()) > 0 {
-		fmt.Println("flush:", len(r.batch.Points()))
-		if err := r.cl.Write(r.batch); err != nil {
+	var err error
+	if r.spool != nil {
+		err = r.spool.Replay(r.cl.Database(), r.cl.Write)
+	}
+
+	if r.batch == nil || len(r.batch.Points()) == 0 {
+		return err
+	}
+
+	if err == nil {
+		log.Debugf("writing %d points to influxdb", len(r.batch.Points()))
+		err = r.cl.Write(r.batch)
+	}
+
+	if err != nil {
+		if r.spool == nil {
+			return err
+		}
+
+		log.Warningf("failed to write %d points to influxdb, spooling them: %s", len(r.batch.Points()), err)
+		if err := r.spool.Push(r.batch); err != nil {
+			return fmt.Errorf("failed to spool points: %s", err)
+		}
+	}
+
+	r.batch = nil
+	return nil
+}
+
+//Rollback deletes all points of the blocks with height above h
+func (r *InfluxRecorder) Rollback(h int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	//flush first so the orphaned points in buffer and in the spool are deleted with the others
+	if err := r._flush(); err != nil {
+		return err
+	}
+
+	//the deleted blocks are recorded again
+	if r.recorded > h {
+		r.recorded = h
+	}
+
+	total, err := r.totalAt(h)
+	if err != nil {
+		return err
+	}
+
+	r.total = total
+
+	for _, series := range []string{InfluxSeriesName, InfluxBlockSeriesName} {
+		ranges, err := r.rolledBack(series, h)
+		if err != nil {
 			return err
 		}
 
-		r.batch = nil
+		if err := r.cl.Delete(series, ranges); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+//times returns the times and heights of the points of the series selected by where, in time order
+func (r *InfluxRecorder) times(series string, where string) ([][2]int64, error) {
+	query := influxdb.NewQuery(fmt.Sprintf("select height from %s where %s;", series, where), r.cl.Database(), "ns")
+	response, err := r.cl.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := response.Error(); err != nil {
+		return nil, err
+	}
+
+	var points [][2]int64
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				var point [2]int64
+				for i := range point {
+					if point[i], err = number(values[i]); err != nil {
+						return nil, err
+					}
+				}
+
+				points = append(points, point)
+			}
+		}
+	}
+
+	return points, nil
+}
+
+//rolledBack returns the time ranges of the points of the series with height above h, the blocks are
+//in time order so it's usually a single range from the first rolled back point. The ranges are split
+//around the points that are kept, if a block has an earlier timestamp than its parent.
+func (r *InfluxRecorder) rolledBack(series string, h int64) ([]TimeRange, error) {
+	points, err := r.times(series, fmt.Sprintf("height > %d", h))
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+
+	if points, err = r.times(series, fmt.Sprintf("time >= %d", points[0][0])); err != nil {
+		return nil, err
+	}
+
+	var ranges []TimeRange
+	open := false
+	for _, point := range points {
+		if point[1] <= h {
+			open = false
+		} else if open {
+			ranges[len(ranges)-1].Stop = point[0]
+		} else {
+			ranges = append(ranges, TimeRange{Start: point[0], Stop: point[0]})
+			open = true
+		}
+	}
+
+	return ranges, nil
+}
+
 //Close the recorder, and flushes any points in buffer
 func (r *InfluxRecorder) Close() error {
 	if r.cancel != nil {
 		r.cancel()
 	}
 
-	return r.flush()
+	err := r.flush()
+	if r.spool != nil {
+		r.spool.Close()
+	}
+
+	return err
 }
 
-func (r *InfluxRecorder) floatValue(response *influxdb.Response, col int) (float64, error) {
+//Spool returns the spool of the failed writes, nil if the recorder has no spool
+func (r *InfluxRecorder) Spool() *Spool {
+	return r.spool
+}
+
+func (r *InfluxRecorder) value(response *influxdb.Response, col int) (interface{}, error) {
 	var value interface{}
 	if len(response.Results) > 0 {
 		result := response.Results[0]
@@ -228,87 +382,427 @@ func (r *InfluxRecorder) floatValue(response *influxdb.Response, col int) (float
 				if col < len(values) {
 					value = row.Values[0][col]
 				} else {
-					return 0, fmt.Errorf("column out of range")
+					return nil, fmt.Errorf("column out of range")
 				}
 			}
 		}
 	}
 
+	if value == nil {
+		return nil, NoValueError
+	}
+
+	return value, nil
+}
+
+func (r *InfluxRecorder) intValue(response *influxdb.Response, col int) (int64, error) {
+	value, err := r.value(response, col)
+	if err != nil {
+		return 0, err
+	}
+
+	return number(value)
+}
+
+//number converts an influx integer value
+func number(value interface{}) (int64, error) {
 	switch value := value.(type) {
-	case nil:
-		return 0, NoValueError
 	case float64:
+		return int64(value), nil
+	case int64:
 		return value, nil
+	case json.Number:
+		return value.Int64()
+	default:
+		return 0, fmt.Errorf("unkown value type '%T' (%v)", value, value)
+	}
+}
+
+//amount returns the amount of an exact field value, or of the approximate field value for the points
+//recorded before the amounts had exact fields
+func amount(exact, approx interface{}) (Currency, error) {
+	switch value := exact.(type) {
+	case string:
+		return ParseCurrency(value)
+	case nil:
+	default:
+		return Currency{}, fmt.Errorf("unkown value type '%T' (%v)", value, value)
+	}
+
+	var f *big.Float
+	switch value := approx.(type) {
+	case nil:
+		return Currency{}, nil
+	case float64:
+		f = big.NewFloat(value)
 	case int64:
-		return float64(value), nil
+		return NewCurrency64(value), nil
 	case json.Number:
-		return value.Float64()
+		var ok bool
+		if f, ok = new(big.Float).SetString(value.String()); !ok {
+			return Currency{}, fmt.Errorf("invalid amount '%s'", value)
+		}
 	default:
-		return 0, fmt.Errorf("unkown value type '%t' (%v)", value, value)
+		return Currency{}, fmt.Errorf("unkown value type '%T' (%v)", value, value)
+	}
+
+	i, _ := f.Int(nil)
+	return NewCurrency(i), nil
+}
+
+//amountColumns returns the columns of the approximate and exact fields of the amounts
+func amountColumns(names ...string) string {
+	var columns []string
+	for _, name := range names {
+		columns = append(columns, name, exactField(name))
 	}
+
+	return strings.Join(columns, ", ")
+}
+
+//sumAmounts returns the sums of the amounts of all rows of the response, the amounts are the columns of
+//amountColumns after the time column. They are added as currencies since influxdb can only sum the
+//approximate amounts.
+func sumAmounts(response *influxdb.Response, count int) ([]Currency, error) {
+	sums := make([]Currency, count)
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) != 1+2*count {
+					return nil, fmt.Errorf("expecting %d columns, got %d", 1+2*count, len(values))
+				}
+
+				for i := range sums {
+					value, err := amount(values[2+2*i], values[1+2*i])
+					if err != nil {
+						return nil, err
+					}
+
+					sums[i] = sums[i].Add(value)
+				}
+			}
+		}
+	}
+
+	return sums, nil
+}
+
+func (r *InfluxRecorder) lastHeight() (int64, error) {
+	//the last point is not the highest block if the chain timestamps are not increasing
+	response, err := r.cl.Query(influxdb.NewQuery("select max(height) as height from block;", r.cl.Database(), ""))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := response.Error(); err != nil {
+		return 0, err
+	}
+
+	return r.intValue(response, 1)
 }
 
 //Height returns the last reported height in the database
 func (r *InfluxRecorder) Height() (int64, error) {
-	response, err := r.cl.Query(influxdb.NewQuery("select last(height) as height from transaction;", r.cl.Database, ""))
+	height, err := r.lastHeight()
+	if err != nil && err != NoValueError {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+//Cursor returns the height of the next block to record, the spooled points are written first. If they
+//can't be written yet, they are replayed before the next points and the cursor is the one of influxdb.
+func (r *InfluxRecorder) Cursor() (int64, error) {
+	if err := r.flush(); err != nil {
+		log.Warningf("failed to replay the spooled points: %s", err)
+	}
+
+	height, err := r.lastHeight()
+	if err == NoValueError {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	//blocks recorded without the issuance or total fields are recorded again, the points have the same
+	//time so they are overwritten
+	response, err := r.cl.Query(influxdb.NewQuery("select count(height), count(total) from block;", r.cl.Database(), ""))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := response.Error(); err != nil {
+		return 0, err
+	}
+
+	blocks, err := r.intValue(response, 1)
 	if err != nil {
 		return 0, err
 	}
 
-	f, err := r.floatValue(response, 1)
+	issuances, err := r.intValue(response, 2)
 	if err != nil && err != NoValueError {
 		return 0, err
 	}
 
-	return int64(f), nil
+	var backfill []heightRange
+	if issuances != blocks {
+		if backfill, err = r.missingIssuance(height); err != nil {
+			return 0, err
+		}
+	}
+
+	next := height + 1
+	if len(backfill) != 0 {
+		next = backfill[0].from
+	}
+
+	//the running total starts from the total of the block before the next one, which has it since
+	//all the blocks without it are recorded again
+	total, err := r.totalAt(next - 1)
+	if err != nil {
+		return 0, err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.backfill = backfill
+	r.recorded = height
+	r.total = total
+	if len(backfill) != 0 {
+		log.Infof("%d blocks have no issuance or total, recording them again from height %d", blocks-issuances, next)
+	}
+
+	return next, nil
+}
+
+//totalAt returns the total tokens after the block at height h
+func (r *InfluxRecorder) totalAt(h int64) (Currency, error) {
+	if h < 0 {
+		return Currency{}, nil
+	}
+
+	response, err := r.cl.Query(
+		influxdb.NewQuery(fmt.Sprintf("select %s from block where height = %d;", amountColumns(influxTotalField), h), r.cl.Database(), ""),
+	)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	if err := response.Error(); err != nil {
+		return Currency{}, err
+	}
+
+	total, ok, err := totalValue(response)
+	if err != nil || ok {
+		return total, err
+	}
+
+	//the blocks recorded by older versions have no total, it's computed once from their issuance
+	response, err = r.cl.Query(
+		influxdb.NewQuery(fmt.Sprintf("select %s from block where height <= %d;", amountColumns("reward", "minted", "burned"), h), r.cl.Database(), ""),
+	)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	if err := response.Error(); err != nil {
+		return Currency{}, err
+	}
+
+	sums, err := sumAmounts(response, 3)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	issuance := blockValue{Reward: sums[0], Minted: sums[1], Burned: sums[2]}
+	return issuance.Total(), nil
+}
+
+//totalValue returns the total of the first row of the response, the total amount columns are the last
+//ones. It's not ok if the row has no total.
+func totalValue(response *influxdb.Response) (Currency, bool, error) {
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) < 3 || values[len(values)-2] == nil && values[len(values)-1] == nil {
+					return Currency{}, false, nil
+				}
+
+				total, err := amount(values[len(values)-1], values[len(values)-2])
+				return total, err == nil, err
+			}
+		}
+	}
+
+	return Currency{}, false, nil
+}
+
+//missingIssuance returns the ranges of the blocks up to height that have no issuance and total fields, or
+//no point at all
+func (r *InfluxRecorder) missingIssuance(height int64) ([]heightRange, error) {
+	response, err := r.cl.Query(influxdb.NewQuery("select height, total from block;", r.cl.Database(), ""))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := response.Error(); err != nil {
+		return nil, err
+	}
+
+	var heights []int64
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) < 3 || values[2] == nil {
+					continue
+				}
+
+				h, err := number(values[1])
+				if err != nil {
+					return nil, err
+				}
+
+				heights = append(heights, h)
+			}
+		}
+	}
+
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+
+	var missing []heightRange
+	next := int64(0)
+	for _, h := range append(heights, height+1) {
+		if h > next {
+			missing = append(missing, heightRange{next, h - 1})
+		}
+
+		if h >= next {
+			next = h + 1
+		}
+	}
+
+	return missing, nil
 }
 
 //TransactedToken return transacted tokens in the look back period
-func (r *InfluxRecorder) TransactedToken(period Period) (float64, error) {
+func (r *InfluxRecorder) TransactedToken(period Period) (Currency, error) {
 	if err := period.Valid(); err != nil {
-		return 0, err
+		return Currency{}, err
 	}
 
 	response, err := r.cl.Query(
 		influxdb.NewQuery(
-			fmt.Sprintf("SELECT sum(input) as total FROM transaction WHERE time >= now() - %s;", period),
-			r.cl.Database,
+			fmt.Sprintf("select %s from transaction where time >= now() - %s;", amountColumns("input"), period),
+			r.cl.Database(),
 			"",
 		),
 	)
 	if err != nil {
-		return 0, err
+		return Currency{}, err
 	}
 
 	if err := response.Error(); err != nil {
-		return 0, err
+		return Currency{}, err
+	}
+
+	sums, err := sumAmounts(response, 1)
+	if err != nil {
+		return Currency{}, err
 	}
 
-	return r.floatValue(response, 1)
+	return sums[0], nil
 }
 
-//TotalTokens total tokens on the chain
-func (r *InfluxRecorder) TotalTokens() (float64, error) {
-	/*
-		TODO:
-		total := amount of coins in genesis coin outputs + (block height - 1) * block reward
+//TotalTokens total tokens on the chain, which is the sum of the block rewards and the minted coins
+//(including the genesis coin outputs) minus the burned coins. It's the running total recorded with
+//the highest block.
+func (r *InfluxRecorder) TotalTokens() (Currency, error) {
+	response, err := r.cl.Query(
+		influxdb.NewQuery(fmt.Sprintf("select top(height, 1), %s from block;", amountColumns(influxTotalField)), r.cl.Database(), ""),
+	)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	if err := response.Error(); err != nil {
+		return Currency{}, err
+	}
+
+	if _, err := r.value(response, 1); err == NoValueError {
+		return Currency{}, nil
+	}
 
-		in case of tf:
-			block reward = 1
-			amount of coins in genesis coin outputs = 695099000
+	total, ok, err := totalValue(response)
+	if err == nil && !ok {
+		//the reporter records the total of the blocks recorded by older versions on start
+		return Currency{}, fmt.Errorf("the last block has no total, it's recorded when the reporter starts")
+	}
+
+	return total, err
+}
+
+//MintedTokens returns the coins minted and burned by block in the look back period, or since the
+//genesis block if period is empty
+func (r *InfluxRecorder) MintedTokens(period Period) ([]Issuance, error) {
+	where := "(minted > 0 or burned > 0)"
+	if len(period) != 0 {
+		if err := period.Valid(); err != nil {
+			return nil, err
+		}
+
+		where = fmt.Sprintf("time >= now() - %s and %s", period, where)
+	}
 
-		we need to change this to work with any rivine block chain by making those variables configurable
-	*/
 	response, err := r.cl.Query(
-		influxdb.NewQuery("SELECT 695099000 + last(height) - 1 as total FROM transaction;", r.cl.Database, ""),
+		influxdb.NewQuery(
+			fmt.Sprintf("select height, %s from block where %s;", amountColumns("minted", "burned"), where),
+			r.cl.Database(),
+			"s",
+		),
 	)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if err := response.Error(); err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	var issuances []Issuance
+	for _, result := range response.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				if len(values) != 6 {
+					return nil, fmt.Errorf("expecting 6 columns, got %d", len(values))
+				}
+
+				var issuance Issuance
+				var err error
+				if issuance.Timestamp, err = number(values[0]); err != nil {
+					return nil, err
+				}
+
+				if issuance.Height, err = number(values[1]); err != nil {
+					return nil, err
+				}
+
+				if issuance.Minted, err = amount(values[3], values[2]); err != nil {
+					return nil, err
+				}
+
+				if issuance.Burned, err = amount(values[5], values[4]); err != nil {
+					return nil, err
+				}
+
+				issuances = append(issuances, issuance)
+			}
+		}
 	}
 
-	return r.floatValue(response, 1)
+	return issuances, nil
 }
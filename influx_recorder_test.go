@@ -0,0 +1,72 @@
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+
+	influxdb "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestBlockPointsTotal(t *testing.T) {
+	var total Currency
+	for _, c := range []struct {
+		blk      *Block
+		expected string
+	}{
+		{seriesBlock(t, 0, 1500000000, "0", TransactionVersionOne, "", "1000", ""), "1000"},
+		{seriesBlock(t, 1, 1500000120, "100", TransactionVersionOne, "200", "190", "10"), "1090"},
+		{seriesBlock(t, 2, 1500000240, "100", TransactionVersionCoinDestruction, "200", "140", "10"), "1130"},
+	} {
+		points, err := blockPoints(c.blk, nil, &total)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields, err := points[len(points)-1].Fields()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fields[exactField(influxTotalField)] != c.expected || total.String() != c.expected {
+			t.Errorf("block %d: got total %v (running %s), expecting %s", c.blk.Height, fields[exactField(influxTotalField)], total, c.expected)
+		}
+	}
+
+	//the line recorder has no running total
+	points, err := blockPoints(seriesBlock(t, 3, 1500000360, "100", TransactionVersionOne, "", "0", ""), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fields, _ := points[len(points)-1].Fields(); fields[influxTotalField] != nil {
+		t.Errorf("got total %v without a running total", fields[influxTotalField])
+	}
+}
+
+func TestTotalValue(t *testing.T) {
+	response := func(values ...[]interface{}) *influxdb.Response {
+		return &influxdb.Response{Results: []influxdb.Result{{Series: []models.Row{{Values: values}}}}}
+	}
+
+	cases := []struct {
+		response *influxdb.Response
+		total    string
+		ok       bool
+	}{
+		{response([]interface{}{json.Number("0"), json.Number("1.5e+20"), "150000000000000000001"}), "150000000000000000001", true},
+		//the points recorded before the exact fields
+		{response([]interface{}{json.Number("0"), json.Number("1500"), nil}), "1500", true},
+		{response([]interface{}{json.Number("0"), nil, nil}), "0", false},
+		{response(), "0", false},
+	}
+
+	for i, c := range cases {
+		total, ok, err := totalValue(c.response)
+		if err != nil {
+			t.Errorf("case %d: %s", i, err)
+		} else if total.String() != c.total || ok != c.ok {
+			t.Errorf("case %d: got total %s (%t), expecting %s (%t)", i, total, ok, c.total, c.ok)
+		}
+	}
+}
@@ -1,26 +1,173 @@
 package reporter
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	influxdb "github.com/influxdata/influxdb/client/v2"
 )
 
-//InfluxClient represents a client to influxdb
-type InfluxClient struct {
+//InfluxClient represents a client to influxdb, queries are written in influxql
+type InfluxClient interface {
+	//Write writes a batch of points
+	Write(bp influxdb.BatchPoints) error
+	//Query runs an influxql query
+	Query(q influxdb.Query) (*influxdb.Response, error)
+	//Delete deletes the points of a series in the given time ranges
+	Delete(series string, ranges []TimeRange) error
+	//Database is the database name, which is the bucket of the 2.x API
+	Database() string
+	Close() error
+}
+
+//InfluxOptions are the options of the influxdb client, the 2.x API is used if a token is given
+type InfluxOptions struct {
+	//Org is the organization of the bucket (2.x API)
+	Org string
+	//Token is the API token (2.x API)
+	Token string
+}
+
+//TimeRange is a range of points times in nanoseconds, start and stop included
+type TimeRange struct {
+	Start int64
+	Stop  int64
+}
+
+//InfluxError is an error response of influxdb
+type InfluxError struct {
+	Code    int
+	Message string
+}
+
+func (e InfluxError) Error() string {
+	return fmt.Sprintf("influxdb: %d: %s", e.Code, e.Message)
+}
+
+//Retryable returns true if influxdb failed to serve a valid request. Other errors are terminal, like
+//points rejected for a field type conflict, writing them again will always give the same error.
+func (e InfluxError) Retryable() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return e.Code >= http.StatusInternalServerError
+}
+
+//influxError returns the InfluxError of a response, the message is the error of the json body or
+//the body itself
+func influxError(response *http.Response) InfluxError {
+	var message struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+
+	data, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+	if err := json.Unmarshal(data, &message); err != nil || len(message.Message)+len(message.Error) == 0 {
+		message.Message = string(data)
+	}
+
+	return InfluxError{Code: response.StatusCode, Message: message.Message + message.Error}
+}
+
+//influxV1Client is a client of the influxdb 1.x API, the queries are run by the influxdb client and the
+//points are written directly so the status of a failed write is known
+type influxV1Client struct {
 	influxdb.Client
-	Database string
+	database string
+	addr     string
+	username string
+	password string
+	client   *http.Client
+}
+
+//Write writes the batch with the write API, the error of a write that influxdb refused is an InfluxError
+func (c *influxV1Client) Write(bp influxdb.BatchPoints) error {
+	var b bytes.Buffer
+	for _, point := range bp.Points() {
+		b.WriteString(point.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+
+	params := url.Values{
+		"db":          {bp.Database()},
+		"rp":          {bp.RetentionPolicy()},
+		"precision":   {bp.Precision()},
+		"consistency": {bp.WriteConsistency()},
+	}
+
+	request, err := http.NewRequest("POST", c.addr+"/write?"+params.Encode(), &b)
+	if err != nil {
+		return err
+	}
+
+	if len(c.username) != 0 {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return influxError(response)
+	}
+
+	return nil
+}
+
+func (c *influxV1Client) Database() string {
+	return c.database
+}
+
+func (c *influxV1Client) Delete(series string, ranges []TimeRange) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	var deletes []string
+	for _, r := range ranges {
+		deletes = append(deletes, fmt.Sprintf("delete from %s where time >= %d and time <= %d;", series, r.Start, r.Stop))
+	}
+
+	response, err := c.Query(influxdb.NewQuery(strings.Join(deletes, " "), c.database, ""))
+	if err != nil {
+		return err
+	}
+
+	return response.Error()
 }
 
-//NewInfluxDB creates a client connection from url
-func newInfluxDB(u string) (*InfluxClient, error) {
+//NewInfluxDB creates a client connection from url, with the 2.x API if the options have a token
+func newInfluxDB(u string, opts InfluxOptions) (InfluxClient, error) {
 	uri, err := url.Parse(u)
 	if err != nil {
 		return nil, err
 	}
 
+	db := strings.Trim(uri.Path, "/")
+	//initialize database
+	if db == "" {
+		db = InfluxDatabaseName
+	}
+
+	if len(opts.Token) != 0 {
+		return newInfluxV2Client(fmt.Sprintf("%s://%s", uri.Scheme, uri.Host), db, opts)
+	} else if len(opts.Org) != 0 {
+		return nil, fmt.Errorf("influxdb organization '%s' without a token, the 2.x API needs a token", opts.Org)
+	}
+
 	var config influxdb.HTTPConfig
 	if uri.User != nil {
 		config.Username = uri.User.Username()
@@ -36,17 +183,20 @@ func newInfluxDB(u string) (*InfluxClient, error) {
 		return nil, err
 	}
 
-	db := strings.Trim(uri.Path, "/")
-	//initialize database
-	if db == "" {
-		db = InfluxDatabaseName
-	}
-
 	query := fmt.Sprintf("create database %s", db)
 	response, err := cl.Query(influxdb.NewQuery(query, "", ""))
 	if err != nil {
 		return nil, err
 	}
 
-	return &InfluxClient{Client: cl, Database: db}, response.Error()
+	client := &influxV1Client{
+		Client:   cl,
+		database: db,
+		addr:     config.Addr,
+		username: config.Username,
+		password: config.Password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	return client, response.Error()
 }
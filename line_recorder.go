@@ -0,0 +1,119 @@
+package reporter
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+
+	influxdb "github.com/influxdata/influxdb/client/v2"
+)
+
+//LineRecorder writes the time series points as influx line protocol to a file or an udp socket, for collectors
+//like telegraf. Its cursor is moved after the points of a block are written, so the points of a block are written
+//again if the reporter stops in between. The points of orphaned blocks can't be deleted, on a chain reorganization
+//the points of the new blocks are written after them.
+type LineRecorder struct {
+	db   *sql.DB
+	file *os.File
+	udp  influxdb.Client
+}
+
+//NewLineRecorder creates a line protocol recorder that writes to u, which is either file:///path or
+//udp://host:port. The cursor is kept in the sqlite database at p
+func NewLineRecorder(u string, p string) (*LineRecorder, error) {
+	uri, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorder LineRecorder
+	switch uri.Scheme {
+	case "file":
+		recorder.file, err = os.OpenFile(uri.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	case "udp":
+		recorder.udp, err = influxdb.NewUDPClient(influxdb.UDPConfig{Addr: uri.Host})
+	default:
+		return nil, fmt.Errorf("invalid line protocol output '%s', expecting file:///path or udp://host:port", u)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if recorder.db, err = openSQLite(p); err != nil {
+		return nil, err
+	}
+
+	if _, err := recorder.db.Exec(cursorSchema); err != nil {
+		return nil, err
+	}
+
+	return &recorder, nil
+}
+
+//Cursor returns the height of the next block to write
+func (r *LineRecorder) Cursor() (int64, error) {
+	return getCursor(r.db)
+}
+
+func (r *LineRecorder) write(points []*influxdb.Point) error {
+	if r.udp != nil {
+		//the udp client splits the batch in packets of whole lines
+		bp, err := influxdb.NewBatchPoints(influxdb.BatchPointsConfig{})
+		if err != nil {
+			return err
+		}
+
+		bp.AddPoints(points)
+		return r.udp.Write(bp)
+	}
+
+	var b bytes.Buffer
+	for _, point := range points {
+		b.WriteString(point.String())
+		b.WriteByte('\n')
+	}
+
+	_, err := r.file.Write(b.Bytes())
+	return err
+}
+
+//Record writes the points of a block
+func (r *LineRecorder) Record(blk *Block) error {
+	//the issuance is already checked against the chain profile by the time series recorder
+	points, err := blockPoints(blk, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := r.write(points); err != nil {
+		return err
+	}
+
+	return setCursor(r.db, blk.Height+1)
+}
+
+//Rollback moves the cursor back to the block after h, so the blocks of the new chain are written
+func (r *LineRecorder) Rollback(h int64) error {
+	cursor, err := getCursor(r.db)
+	if err != nil || cursor <= h+1 {
+		return err
+	}
+
+	return setCursor(r.db, h+1)
+}
+
+//Close the recorder and its output
+func (r *LineRecorder) Close() error {
+	if r.udp != nil {
+		r.udp.Close()
+	}
+
+	if r.file != nil {
+		r.file.Close()
+	}
+
+	return r.db.Close()
+}
@@ -6,10 +6,18 @@ import (
 
 type MemoryRecorder struct{}
 
+func (m *MemoryRecorder) Cursor() (int64, error) {
+	return 0, fmt.Errorf("not implemented")
+}
+
 func (m *MemoryRecorder) Record(blk *Block) error {
 	return fmt.Errorf("not implemented")
 }
 
+func (m *MemoryRecorder) Rollback(h int64) error {
+	return fmt.Errorf("not implemented")
+}
+
 func (m *MemoryRecorder) Close() error {
 	return nil
 }
@@ -0,0 +1,231 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//CrossCheck what to do when the explorers disagree on the block at some height
+type CrossCheck string
+
+const (
+	//CrossCheckOff only gets the block from a single explorer
+	CrossCheckOff CrossCheck = "off"
+	//CrossCheckLog logs a warning when the explorers disagree
+	CrossCheckLog CrossCheck = "log"
+	//CrossCheckHalt stops scanning when the explorers disagree
+	CrossCheckHalt CrossCheck = "halt"
+)
+
+//Valid validate cross check mode
+func (c CrossCheck) Valid() error {
+	switch c {
+	case CrossCheckOff, CrossCheckLog, CrossCheckHalt:
+		return nil
+	}
+
+	return fmt.Errorf("invalid cross check mode '%s', expecting one of (off, log, halt)", c)
+}
+
+//CrossCheckError is returned when the explorers disagree on a block in halt mode, it's terminal
+type CrossCheckError struct {
+	Height int64
+	//IDs of the block by explorer
+	IDs map[string]string
+}
+
+func (e CrossCheckError) Error() string {
+	return fmt.Sprintf("explorers disagree on block (%d): %v", e.Height, e.IDs)
+}
+
+//Retryable always returns false, the explorers need to be fixed first
+func (e CrossCheckError) Retryable() bool {
+	return false
+}
+
+//multiExplorer gets the blocks from the first explorer that has them. When an explorer is down
+//or lagging behind, the next one is used and kept until it fails in turn.
+type multiExplorer struct {
+	names     []string
+	explorers []Explorer
+	opts      ExplorerOptions
+	check     CrossCheck
+
+	current int
+	m       sync.Mutex
+}
+
+//NewMultiExplorer creates an explorer that fails over between several explorers, names are used in the
+//logs and errors. The options retries apply to a full round over all explorers, so the explorers should
+//not retry themselves.
+func NewMultiExplorer(names []string, explorers []Explorer, opts ExplorerOptions, check CrossCheck) (Explorer, error) {
+	if len(explorers) == 0 || len(names) != len(explorers) {
+		return nil, fmt.Errorf("expecting a name for each of one or more explorers")
+	}
+
+	if err := check.Valid(); err != nil {
+		return nil, err
+	}
+
+	for i, explorer := range explorers {
+		//the blocks of a daemon are only complete while it scans them in order
+		if _, ok := explorer.(*daemonExplorer); ok {
+			return nil, fmt.Errorf("explorer %s: a rivine daemon can't be used with other explorers", names[i])
+		}
+	}
+
+	if opts.Window < 1 {
+		opts.Window = 1
+	}
+
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = opts.Backoff
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+
+	return &multiExplorer{names: names, explorers: explorers, opts: opts, check: check}, nil
+}
+
+func (e *multiExplorer) primary() int {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return e.current
+}
+
+//use makes explorer i the current one
+func (e *multiExplorer) use(i int) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.current != i {
+		log.Warningf("failing over from explorer %s to %s", e.names[e.current], e.names[i])
+		e.current = i
+	}
+}
+
+//get tries all the explorers once starting with the current one, and fails over to the first one
+//that has the block. If an explorer doesn't have the block, the chain didn't reach it yet or this
+//explorer is lagging behind, so a no block found error is returned if none of them has it.
+func (e *multiExplorer) get(ctx context.Context, h int64) (*Block, int, error) {
+	first := e.primary()
+	var notFound, lastErr error
+	for n := 0; n < len(e.explorers); n++ {
+		i := (first + n) % len(e.explorers)
+		blk, err := e.explorers[i].GetBlock(ctx, h)
+		if err == nil {
+			e.use(i)
+			return blk, i, nil
+		}
+
+		if eerr, ok := err.(ExplorerError); ok && eerr.NoBlockFound() {
+			notFound = err
+			continue
+		}
+
+		log.Errorf("explorer %s: get block (%d): %s", e.names[i], h, err)
+		lastErr = err
+	}
+
+	if notFound != nil {
+		return nil, first, notFound
+	}
+
+	return nil, first, lastErr
+}
+
+func (e *multiExplorer) GetBlock(ctx context.Context, h int64) (*Block, error) {
+	var blk *Block
+	var from int
+	err := retry(ctx, e.opts, fmt.Sprintf("get block (%d)", h), func() error {
+		var err error
+		blk, from, err = e.get(ctx, h)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if e.check == CrossCheckOff || len(e.explorers) == 1 {
+		return blk, nil
+	}
+
+	return blk, e.crossCheck(ctx, blk, from)
+}
+
+//ChainHeight returns the highest chain height of the explorers that are up
+func (e *multiExplorer) ChainHeight(ctx context.Context) (int64, error) {
+	var height int64 = -1
+	var lastErr error
+	for i, explorer := range e.explorers {
+		h, err := explorer.ChainHeight(ctx)
+		if err != nil {
+			log.Errorf("explorer %s: get chain height: %s", e.names[i], err)
+			lastErr = err
+			continue
+		}
+
+		if h > height {
+			height = h
+		}
+	}
+
+	if height < 0 {
+		return 0, lastErr
+	}
+
+	return height, nil
+}
+
+//crossCheck compares the block id with the other explorers, explorers that don't have the
+//block yet or fail are skipped
+func (e *multiExplorer) crossCheck(ctx context.Context, blk *Block, from int) error {
+	ids := map[string]string{e.names[from]: blk.ID}
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	for i, explorer := range e.explorers {
+		if i == from {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, explorer Explorer) {
+			defer wg.Done()
+			other, err := explorer.GetBlock(ctx, blk.Height)
+			if err != nil {
+				log.Debugf("cross check of block (%d) with explorer %s skipped: %s", blk.Height, name, err)
+				return
+			}
+
+			m.Lock()
+			ids[name] = other.ID
+			m.Unlock()
+		}(e.names[i], explorer)
+	}
+
+	wg.Wait()
+
+	for _, id := range ids {
+		if id == blk.ID {
+			continue
+		}
+
+		err := CrossCheckError{Height: blk.Height, IDs: ids}
+		if e.check == CrossCheckHalt {
+			return err
+		}
+
+		log.Warning(err.Error())
+		break
+	}
+
+	return nil
+}
+
+func (e *multiExplorer) Scan(head int64) Scanner {
+	return newScanner(e, head, e.opts)
+}
@@ -0,0 +1,304 @@
+package reporter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+//Output is a coin output
+type Output struct {
+	ID         string
+	Value      Currency
+	UnlockHash string
+	Condition  Condition
+	Height     int64
+}
+
+//OutputRecorder keeps track of the unspent coin outputs, balances are derived from the
+//unspent outputs of an address. The block stake outputs are kept in their own table.
+type OutputRecorder struct {
+	db *sql.DB
+}
+
+//NewOutputRecorder creates a new output recorder
+func NewOutputRecorder(p string) (*OutputRecorder, error) {
+	db, err := openSQLite(p)
+	if err != nil {
+		return nil, err
+	}
+	exec := `
+	create table if not exists output (
+		id text not null primary key,
+		address text not null,
+		value text not null,
+		condition text not null,
+		height integer not null,
+		spent integer
+	);
+
+	create index if not exists output_address_index on output (address, spent);
+	create index if not exists output_height_index on output (height);
+	create index if not exists output_spent_index on output (spent);
+
+	create table if not exists blockstake_output (
+		id text not null primary key,
+		address text not null,
+		value text not null,
+		condition text not null,
+		height integer not null,
+		spent integer
+	);
+
+	create index if not exists blockstake_output_height_index on blockstake_output (height);
+	create index if not exists blockstake_output_spent_index on blockstake_output (spent);
+	` + cursorSchema
+	_, err = db.Exec(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutputRecorder{db: db}, nil
+}
+
+//outputTables are the tables of the coin and block stake outputs
+var outputTables = []string{"output", "blockstake_output"}
+
+func (r *OutputRecorder) add(tx querier, table string, blk *Block, id string, output InputOutput, hash string) error {
+	if len(output.UnlockHash) != 0 {
+		hash = output.UnlockHash
+		if output.Condition.Type == NilCondtion && len(output.Condition.Data) == 0 {
+			//legacy outputs only have an unlock hash
+			output.Condition = NewCondition(UnlockHashConditionData{UnlockHash: hash})
+		}
+	}
+
+	condition, err := json.Marshal(output.Condition)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("insert or replace into %s (id, address, value, condition, height) values (?, ?, ?, ?, ?);", table),
+		id, hash, output.Value.String(), string(condition), blk.Height,
+	)
+
+	return err
+}
+
+//Cursor returns the height of the next block to record
+func (r *OutputRecorder) Cursor() (int64, error) {
+	return getCursor(r.db)
+}
+
+//Record record a block on the output recorder, the block is applied atomically with the cursor
+func (r *OutputRecorder) Record(blk *Block) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(tx, blk); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setCursor(tx, blk.Height+1); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *OutputRecorder) record(tx *sql.Tx, blk *Block) error {
+	for i, payout := range blk.RawBlock.MinerPayouts {
+		if i >= len(blk.MinerPayoutIDs) {
+			return fmt.Errorf("missing id of miner payout (%d)", i)
+		}
+
+		if err := r.add(tx, "output", blk, blk.MinerPayoutIDs[i], payout, payout.UnlockHash); err != nil {
+			return fmt.Errorf("miner payout (%d): %v", i, err)
+		}
+	}
+
+	for i, txn := range blk.Transactions {
+		for o, output := range txn.RawTransaction.Data.CoinOutputs {
+			if o >= len(txn.CoinOutputIDs) {
+				return fmt.Errorf("transaction (%d): missing id of coin output (%d)", i, o)
+			}
+
+			var hash string
+			if o < len(txn.CoinOutputUnlockHashes) {
+				hash = txn.CoinOutputUnlockHashes[o]
+			}
+
+			if err := r.add(tx, "output", blk, txn.CoinOutputIDs[o], output, hash); err != nil {
+				return fmt.Errorf("transaction (%d): coin output (%d): %v", i, o, err)
+			}
+		}
+
+		for _, input := range txn.RawTransaction.Data.CoinInputs {
+			if _, err := tx.Exec("update output set spent = ? where id = ?;", blk.Height, input.ParentID); err != nil {
+				return fmt.Errorf("transaction (%d): coin input: %v", i, err)
+			}
+		}
+
+		for o, output := range txn.RawTransaction.Data.BlockStakeOutputs {
+			if o >= len(txn.BlockStakeOutputIDs) {
+				return fmt.Errorf("transaction (%d): missing id of block stake output (%d)", i, o)
+			}
+
+			var hash string
+			if o < len(txn.BlockStakeOutputUnlockHashes) {
+				hash = txn.BlockStakeOutputUnlockHashes[o]
+			}
+
+			if err := r.add(tx, "blockstake_output", blk, txn.BlockStakeOutputIDs[o], output, hash); err != nil {
+				return fmt.Errorf("transaction (%d): block stake output (%d): %v", i, o, err)
+			}
+		}
+
+		for _, input := range txn.RawTransaction.Data.BlockStakeInputs {
+			if _, err := tx.Exec("update blockstake_output set spent = ? where id = ?;", blk.Height, input.ParentID); err != nil {
+				return fmt.Errorf("transaction (%d): block stake input: %v", i, err)
+			}
+		}
+	}
+
+	//spent outputs are only needed as long as their block can be rolled back
+	for _, table := range outputTables {
+		if _, err := tx.Exec(fmt.Sprintf("delete from %s where spent <= ?;", table), blk.Height-MaxReorgDepth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Rollback reverts the outputs created and spent by all blocks with height above h
+func (r *OutputRecorder) Rollback(h int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.rollback(tx, h); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *OutputRecorder) rollback(tx *sql.Tx, h int64) error {
+	for _, table := range outputTables {
+		if _, err := tx.Exec(fmt.Sprintf("delete from %s where height > ?;", table), h); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("update %s set spent = null where spent > ?;", table), h); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := getCursor(tx)
+	if err != nil || cursor <= h+1 {
+		return err
+	}
+
+	return setCursor(tx, h+1)
+}
+
+//Close the recorder, any calls to record after that will fail
+func (r *OutputRecorder) Close() error {
+	return r.db.Close()
+}
+
+//Outputs returns the unspent outputs of this address, ordered by creation height
+func (r *OutputRecorder) Outputs(address string, page, size int) ([]Output, error) {
+	rows, err := r.db.Query(
+		"select id, address, value, condition, height from output where address = ? and spent is null order by height, id limit ? offset ?;",
+		address, size, page*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var outputs []Output
+	for rows.Next() {
+		var output Output
+		var value, condition string
+		if err := rows.Scan(&output.ID, &output.UnlockHash, &value, &condition, &output.Height); err != nil {
+			return nil, err
+		}
+
+		if output.Value, err = ParseCurrency(value); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(condition), &output.Condition); err != nil {
+			return nil, err
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	return outputs, rows.Err()
+}
+
+//Output returns the coin output with the given id, spent outputs are only kept for MaxReorgDepth blocks
+func (r *OutputRecorder) Output(id string) (Output, error) {
+	return r.output("output", id)
+}
+
+//BlockStakeOutput returns the block stake output with the given id, spent outputs are only kept for
+//MaxReorgDepth blocks
+func (r *OutputRecorder) BlockStakeOutput(id string) (Output, error) {
+	return r.output("blockstake_output", id)
+}
+
+func (r *OutputRecorder) output(table, id string) (Output, error) {
+	output := Output{ID: id}
+	var value, condition string
+	row := r.db.QueryRow(fmt.Sprintf("select address, value, condition, height from %s where id = ?;", table), id)
+	if err := row.Scan(&output.UnlockHash, &value, &condition, &output.Height); err != nil {
+		return output, err
+	}
+
+	var err error
+	if output.Value, err = ParseCurrency(value); err != nil {
+		return output, err
+	}
+
+	return output, json.Unmarshal([]byte(condition), &output.Condition)
+}
+
+//Balance returns the sum of the unspent outputs of this address
+func (r *OutputRecorder) Balance(address string) (Currency, error) {
+	rows, err := r.db.Query("select value from output where address = ? and spent is null;", address)
+	if err != nil {
+		return Currency{}, err
+	}
+
+	defer rows.Close()
+
+	var total Currency
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return total, err
+		}
+
+		amount, err := ParseCurrency(value)
+		if err != nil {
+			return total, err
+		}
+
+		total = total.Add(amount)
+	}
+
+	return total, rows.Err()
+}
@@ -8,6 +8,11 @@ var (
 
 //Recorder interface
 type Recorder interface {
+	//Cursor returns the height of the next block to record, it is persisted atomically
+	//with the recorded data
+	Cursor() (int64, error)
 	Record(blk *Block) error
+	//Rollback reverts all recorded blocks with height above h
+	Rollback(h int64) error
 	Close() error
 }
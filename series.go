@@ -0,0 +1,210 @@
+package reporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	//LastHour period
+	LastHour Period = "1h"
+	//LastDay period
+	LastDay Period = "1d"
+	//LastWeek period
+	LastWeek Period = "1w"
+	//LastMonth period
+	LastMonth Period = "4w"
+)
+
+var (
+	periodP      = regexp.MustCompile(`^\d+(\w{1,2})$`)
+	periodSuffix = map[string]time.Duration{
+		"u":  time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+)
+
+//Period look back period
+type Period string
+
+//Valid validate period string
+func (p Period) Valid() error {
+	m := periodP.FindStringSubmatch(string(p))
+	if len(m) == 0 {
+		return fmt.Errorf("invalid period format, expecting <number><suffix>")
+	}
+
+	if _, ok := periodSuffix[m[1]]; !ok {
+		return fmt.Errorf("invalid period suffix, where suffix is one of (u, ms, s, m, h, d, w)")
+	}
+
+	return nil
+}
+
+//Since returns the start of the period that ends now
+func (p Period) Since() (time.Time, error) {
+	if err := p.Valid(); err != nil {
+		return time.Time{}, err
+	}
+
+	suffix := periodP.FindStringSubmatch(string(p))[1]
+	n, err := strconv.ParseInt(string(p)[:len(p)-len(suffix)], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().Add(-time.Duration(n) * periodSuffix[suffix]), nil
+}
+
+//TimeSeries is a recorder of the blocks and transactions values over time, it's implemented by
+//the InfluxRecorder and the embedded SeriesRecorder
+type TimeSeries interface {
+	Recorder
+	//Height returns the height of the last recorded block
+	Height() (int64, error)
+	//TotalTokens returns the tokens on the chain
+	TotalTokens() (Currency, error)
+	//TransactedToken returns the tokens spent by the transactions in the look back period
+	TransactedToken(period Period) (Currency, error)
+	//MintedTokens returns the coins minted and burned by block in the look back period, or since the
+	//genesis block if period is empty
+	MintedTokens(period Period) ([]Issuance, error)
+}
+
+type txnValue struct {
+	Output          Currency
+	Input           Currency
+	Fees            Currency
+	InputAddresses  int
+	OutputAddresses int
+}
+
+//issuance returns the coins created or destroyed by the transaction at height h, according to its version:
+//
+//  - the minter definition, coin creation and ERC20 coin creation transactions have no inputs, they mint
+//    their coin outputs and miner fees
+//  - the coin destruction transaction burns the inputs it doesn't refund or pay as fee
+//  - the ERC20 conversion burns the converted value
+//  - the genesis coin outputs have no inputs, so they are minted at height 0
+//
+//The other transactions only move existing coins.
+func issuance(txn *Transaction, v *txnValue, h int64) (minted Currency, burned Currency) {
+	data := &txn.RawTransaction.Data
+	switch txn.RawTransaction.Version {
+	case TransactionVersionMinterDefinition, TransactionVersionCoinCreation, TransactionVersionERC20CoinCreation:
+		return v.Output.Add(v.Fees), Currency{}
+	case TransactionVersionCoinDestruction:
+		return Currency{}, v.Input.Sub(v.Output).Sub(v.Fees)
+	case TransactionVersionERC20Conversion:
+		if data.Value != nil {
+			return Currency{}, *data.Value
+		}
+	}
+
+	if h == 0 {
+		return v.Output.Add(v.Fees).Sub(v.Input), Currency{}
+	}
+
+	return Currency{}, Currency{}
+}
+
+//blockValue is the coin issuance of a block
+type blockValue struct {
+	//Reward is the block creator reward, which is the miner payouts minus the transaction fees
+	Reward Currency
+	Minted Currency
+	Burned Currency
+}
+
+//Total returns the coins added to the supply by the block
+func (v *blockValue) Total() Currency {
+	return v.Reward.Add(v.Minted).Sub(v.Burned)
+}
+
+//Issuance are the coins minted and burned by a block
+type Issuance struct {
+	Height    int64
+	Timestamp int64
+	Minted    Currency
+	Burned    Currency
+}
+
+func aggregate(txn *Transaction) txnValue {
+	var values txnValue
+
+	//updating transaction fees
+	for _, fee := range txn.RawTransaction.Data.MinerFees {
+		values.Fees = values.Fees.Add(fee)
+	}
+
+	for _, output := range txn.RawTransaction.Data.CoinOutputs {
+		values.OutputAddresses++
+		values.Output = values.Output.Add(output.Value)
+	}
+
+	for _, input := range txn.CoinInputOutputs {
+		values.InputAddresses++
+		values.Input = values.Input.Add(input.Value)
+	}
+
+	//the 3bot transactions pay the bot fee with the inputs they don't refund, and the ERC20 address
+	//registration pays its registration fee. The fees are paid out with the miner payouts like the
+	//transaction fee, so they are not burned.
+	switch txn.RawTransaction.Version {
+	case TransactionVersionBotRegistration, TransactionVersionBotRecordUpdate, TransactionVersionBotNameTransfer:
+		if fee := values.Input.Sub(values.Output).Sub(values.Fees); fee.Sign() > 0 {
+			values.Fees = values.Fees.Add(fee)
+		}
+	case TransactionVersionERC20AddressRegistration:
+		if fee := txn.RawTransaction.Data.RegistrationFee; fee != nil {
+			values.Fees = values.Fees.Add(*fee)
+		}
+	}
+
+	return values
+}
+
+//blockValues returns the values of the block transactions and the block issuance, the issuance is
+//checked against the chain profile if given. A mismatch is only logged unless the profile is strict.
+func blockValues(blk *Block, chain *Chain) ([]txnValue, blockValue, error) {
+	var block blockValue
+	for _, payout := range blk.RawBlock.MinerPayouts {
+		block.Reward = block.Reward.Add(payout.Value)
+	}
+
+	values := make([]txnValue, 0, len(blk.Transactions))
+	for i := range blk.Transactions {
+		value := aggregate(&blk.Transactions[i])
+
+		minted, burned := issuance(&blk.Transactions[i], &value, blk.Height)
+		block.Minted = block.Minted.Add(minted)
+		block.Burned = block.Burned.Add(burned)
+		//the transaction fees are paid to the block creator with the reward
+		block.Reward = block.Reward.Sub(value.Fees)
+
+		values = append(values, value)
+	}
+
+	if block.Reward.Sign() < 0 {
+		//fees that are not paid out are burned
+		block.Burned = block.Burned.Sub(block.Reward)
+		block.Reward = Currency{}
+	}
+
+	if chain != nil {
+		if err := chain.Check(blk.Height, block.Reward, block.Minted); err != nil && chain.Strict {
+			return nil, block, err
+		} else if err != nil {
+			log.Warningf("%s, is it the right chain profile?", err)
+		}
+	}
+
+	return values, block, nil
+}
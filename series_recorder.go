@@ -0,0 +1,422 @@
+package reporter
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+//SeriesRecorder is an embedded time series store, it records the same values as the InfluxRecorder
+//in a sqlite database so the reporter can run without influxdb. Amounts are stored as sortable text like
+//in the address store, and each block has the running total of the tokens on the chain.
+type SeriesRecorder struct {
+	db    *Store
+	chain *Chain
+}
+
+//seriesMigrations are the schema migrations of the embedded series, the first one is the schema of the
+//previous versions which stored the amounts as integers. They are copied as text by upgrade.
+var seriesMigrations = []Migration{
+	{1, `
+	create table if not exists block (
+		height integer not null primary key,
+		timestamp integer not null,
+		transactions integer not null,
+		reward integer not null,
+		minted integer not null,
+		burned integer not null
+	);
+
+	create index if not exists block_timestamp_index on block (timestamp);
+
+	create table if not exists txn (
+		height integer not null,
+		idx integer not null,
+		timestamp integer not null,
+		input integer not null,
+		output integer not null,
+		fees integer not null,
+		input_addresses integer not null,
+		output_addresses integer not null,
+		primary key (height, idx)
+	);
+
+	create index if not exists txn_timestamp_index on txn (timestamp);
+	` + cursorSchema},
+	{2, `
+	drop index block_timestamp_index;
+	drop index txn_timestamp_index;
+	alter table block rename to block_v1;
+	alter table txn rename to txn_v1;
+
+	create table block (
+		height integer not null primary key,
+		timestamp integer not null,
+		transactions integer not null,
+		reward text not null,
+		minted text not null,
+		burned text not null,
+		total text not null
+	);
+
+	create index block_timestamp_index on block (timestamp);
+
+	create table txn (
+		height integer not null,
+		idx integer not null,
+		timestamp integer not null,
+		input text not null,
+		output text not null,
+		fees text not null,
+		input_addresses integer not null,
+		output_addresses integer not null,
+		primary key (height, idx)
+	);
+
+	create index txn_timestamp_index on txn (timestamp);
+	`},
+}
+
+//NewSeriesRecorder creates a new embedded time series recorder, the block issuance is checked against the
+//chain profile if given
+func NewSeriesRecorder(p string, chain *Chain) (*SeriesRecorder, error) {
+	db, err := OpenStore("sqlite://" + p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Migrate(seriesMigrations); err != nil {
+		return nil, err
+	}
+
+	recorder := &SeriesRecorder{db: db, chain: chain}
+	return recorder, recorder.upgrade()
+}
+
+//upgrade copies the integer amounts of the tables of the first schema version to the current tables,
+//with the running totals of the blocks, and drops them
+func (r *SeriesRecorder) upgrade() error {
+	var count int
+	row := r.db.QueryRow("select count(*) from sqlite_master where type = 'table' and name = 'block_v1';")
+	if err := row.Scan(&count); err != nil || count == 0 {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := upgradeSeries(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("upgrade series amounts: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func upgradeSeries(tx *sql.Tx) error {
+	rows, err := tx.Query("select height, idx, timestamp, input, output, fees, input_addresses, output_addresses from txn_v1;")
+	if err != nil {
+		return err
+	}
+
+	var txns [][8]int64
+	for rows.Next() {
+		var values [8]int64
+		if err := rows.Scan(&values[0], &values[1], &values[2], &values[3], &values[4], &values[5], &values[6], &values[7]); err != nil {
+			rows.Close()
+			return err
+		}
+
+		txns = append(txns, values)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, v := range txns {
+		_, err := tx.Exec(
+			`insert into txn (height, idx, timestamp, input, output, fees, input_addresses, output_addresses)
+			values (?, ?, ?, ?, ?, ?, ?, ?);`,
+			v[0], v[1], v[2], NewCurrency64(v[3]).sortable(), NewCurrency64(v[4]).sortable(), NewCurrency64(v[5]).sortable(), v[6], v[7],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.Query("select height, timestamp, transactions, reward, minted, burned from block_v1 order by height;")
+	if err != nil {
+		return err
+	}
+
+	var blocks [][6]int64
+	for rows.Next() {
+		var values [6]int64
+		if err := rows.Scan(&values[0], &values[1], &values[2], &values[3], &values[4], &values[5]); err != nil {
+			rows.Close()
+			return err
+		}
+
+		blocks = append(blocks, values)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var total Currency
+	for _, v := range blocks {
+		issuance := blockValue{Reward: NewCurrency64(v[3]), Minted: NewCurrency64(v[4]), Burned: NewCurrency64(v[5])}
+		total = total.Add(issuance.Total())
+		if err := insertBlock(tx, v[0], v[1], v[2], &issuance, total); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("drop table txn_v1; drop table block_v1;"); err != nil {
+		return err
+	}
+
+	log.Infof("upgraded the amounts of %d blocks and %d transactions of the embedded series", len(blocks), len(txns))
+	return nil
+}
+
+func insertBlock(tx querier, height, timestamp, transactions int64, issuance *blockValue, total Currency) error {
+	_, err := tx.Exec(
+		`insert or replace into block (height, timestamp, transactions, reward, minted, burned, total)
+		values (?, ?, ?, ?, ?, ?, ?);`,
+		height, timestamp, transactions, issuance.Reward.sortable(), issuance.Minted.sortable(), issuance.Burned.sortable(), total.sortable(),
+	)
+
+	return err
+}
+
+//Cursor returns the height of the next block to record
+func (r *SeriesRecorder) Cursor() (int64, error) {
+	return getCursor(r.db)
+}
+
+//Record record a block on the series recorder, the block is applied atomically with the cursor
+func (r *SeriesRecorder) Record(blk *Block) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(tx, blk); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := setCursor(tx, blk.Height+1); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//totalAt returns the total tokens after the block at height h
+func (r *SeriesRecorder) totalAt(q querier, h int64) (Currency, error) {
+	if h < 0 {
+		return Currency{}, nil
+	}
+
+	var total string
+	if err := q.QueryRow("select total from block where height = ?;", h).Scan(&total); err == sql.ErrNoRows {
+		return Currency{}, fmt.Errorf("no total recorded at height %d", h)
+	} else if err != nil {
+		return Currency{}, err
+	}
+
+	return parseSortable(total)
+}
+
+func (r *SeriesRecorder) record(tx *sql.Tx, blk *Block) error {
+	values, issuance, err := blockValues(blk, r.chain)
+	if err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		_, err := tx.Exec(
+			`insert or replace into txn (height, idx, timestamp, input, output, fees, input_addresses, output_addresses)
+			values (?, ?, ?, ?, ?, ?, ?, ?);`,
+			blk.Height, i, blk.RawBlock.Timestamp, value.Input.sortable(), value.Output.sortable(), value.Fees.sortable(),
+			value.InputAddresses, value.OutputAddresses,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	//the blocks are recorded in order, so the previous block has its total already
+	total, err := r.totalAt(tx, blk.Height-1)
+	if err != nil {
+		return err
+	}
+
+	return insertBlock(tx, blk.Height, blk.RawBlock.Timestamp, int64(len(blk.Transactions)), &issuance, total.Add(issuance.Total()))
+}
+
+//Rollback deletes the values of all blocks with height above h
+func (r *SeriesRecorder) Rollback(h int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := r.rollback(tx, h); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SeriesRecorder) rollback(tx *sql.Tx, h int64) error {
+	for _, table := range []string{"txn", "block"} {
+		if _, err := tx.Exec("delete from "+table+" where height > ?;", h); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := getCursor(tx)
+	if err != nil || cursor <= h+1 {
+		return err
+	}
+
+	return setCursor(tx, h+1)
+}
+
+//Close the recorder, any calls to record after that will fail
+func (r *SeriesRecorder) Close() error {
+	return r.db.Close()
+}
+
+//Height returns the height of the last recorded block
+func (r *SeriesRecorder) Height() (int64, error) {
+	row := r.db.QueryRow("select max(height) from block;")
+	var height sql.NullInt64
+	if err := row.Scan(&height); err != nil {
+		return 0, err
+	}
+
+	return height.Int64, nil
+}
+
+//sum returns the sums of the amount columns of all rows of the query
+func (r *SeriesRecorder) sum(query string, args ...interface{}) ([]Currency, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make([]Currency, len(columns))
+	values := make([]string, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		for i, value := range values {
+			amount, err := parseSortable(value)
+			if err != nil {
+				return nil, err
+			}
+
+			sums[i] = sums[i].Add(amount)
+		}
+	}
+
+	return sums, rows.Err()
+}
+
+//TotalTokens total tokens on the chain, which is the sum of the block rewards and the minted coins
+//(including the genesis coin outputs) minus the burned coins. It's the running total of the highest block.
+func (r *SeriesRecorder) TotalTokens() (Currency, error) {
+	var total string
+	if err := r.db.QueryRow("select total from block order by height desc limit 1;").Scan(&total); err == sql.ErrNoRows {
+		return Currency{}, nil
+	} else if err != nil {
+		return Currency{}, err
+	}
+
+	return parseSortable(total)
+}
+
+//TransactedToken return transacted tokens in the look back period
+func (r *SeriesRecorder) TransactedToken(period Period) (Currency, error) {
+	since, err := period.Since()
+	if err != nil {
+		return Currency{}, err
+	}
+
+	sums, err := r.sum("select input from txn where timestamp >= ?;", since.Unix())
+	if err != nil {
+		return Currency{}, err
+	}
+
+	return sums[0], nil
+}
+
+//MintedTokens returns the coins minted and burned by block in the look back period, or since the
+//genesis block if period is empty
+func (r *SeriesRecorder) MintedTokens(period Period) ([]Issuance, error) {
+	var since time.Time
+	if len(period) != 0 {
+		var err error
+		if since, err = period.Since(); err != nil {
+			return nil, err
+		}
+	}
+
+	zero := Currency{}.sortable()
+	rows, err := r.db.Query(
+		"select height, timestamp, minted, burned from block where timestamp >= ? and (minted != ? or burned != ?) order by height;",
+		since.Unix(), zero, zero,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var issuances []Issuance
+	for rows.Next() {
+		var issuance Issuance
+		var minted, burned string
+		if err := rows.Scan(&issuance.Height, &issuance.Timestamp, &minted, &burned); err != nil {
+			return nil, err
+		}
+
+		if issuance.Minted, err = parseSortable(minted); err != nil {
+			return nil, err
+		}
+
+		if issuance.Burned, err = parseSortable(burned); err != nil {
+			return nil, err
+		}
+
+		issuances = append(issuances, issuance)
+	}
+
+	return issuances, rows.Err()
+}
@@ -0,0 +1,234 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//seriesBlock returns a block created at timestamp, with a miner payout of reward and a transaction of the
+//given version, coin inputs, outputs and fee. A genesis transaction without inputs mints its outputs.
+func seriesBlock(t *testing.T, h int64, timestamp int64, reward string, version TransactionVersion, input, output, fee string) *Block {
+	var inputs, fees string
+	if len(input) != 0 {
+		inputs = fmt.Sprintf(`{"value": "%s", "condition": {"type": 1, "data": {"unlockhash": "%s"}}}`, input, testUnlockHash1)
+	}
+
+	if len(fee) != 0 {
+		fees = fmt.Sprintf(`"%s"`, fee)
+	}
+
+	text := fmt.Sprintf(`{
+		"blockid": "block-%d", "height": %d,
+		"rawblock": {"timestamp": %d, "minerpayouts": [{"value": "%s", "unlockhash": "%s"}]},
+		"transactions": [{
+			"id": "txn-%d", "height": %d,
+			"rawtransaction": {"version": %d, "data": {
+				"coinoutputs": [{"value": "%s", "condition": {"type": 1, "data": {"unlockhash": "%s"}}}],
+				"minerfees": [%s]
+			}},
+			"coininputoutputs": [%s]
+		}]
+	}`, h, h, timestamp, reward, testUnlockHash3, h, h, version, output, testUnlockHash2, fees, inputs)
+
+	var blk Block
+	if err := json.Unmarshal([]byte(text), &blk); err != nil {
+		t.Fatal(err)
+	}
+
+	return &blk
+}
+
+func TestSeriesRecorder(t *testing.T) {
+	recorder, err := NewSeriesRecorder(filepath.Join(t.TempDir(), "series.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	now := time.Now().Unix()
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	blocks := []*Block{
+		//the genesis outputs are minted
+		seriesBlock(t, 0, old, "0", TransactionVersionOne, "", "1000", ""),
+		//the fee is paid out with the reward
+		seriesBlock(t, 1, old, "110", TransactionVersionOne, "500", "490", "10"),
+		seriesBlock(t, 2, now, "100", TransactionVersionOne, "300", "300", ""),
+		//the coin destruction burns 50 coins
+		seriesBlock(t, 3, now, "100", TransactionVersionCoinDestruction, "200", "140", "10"),
+	}
+
+	for _, blk := range blocks {
+		if err := recorder.Record(blk); err != nil {
+			t.Fatalf("block %d: %v", blk.Height, err)
+		}
+	}
+
+	check := func(height int64, total, transacted string, issuances int) {
+		t.Helper()
+
+		if actual, err := recorder.Height(); err != nil {
+			t.Error(err)
+		} else if actual != height {
+			t.Errorf("got height %d, expecting %d", actual, height)
+		}
+
+		if actual, err := recorder.TotalTokens(); err != nil {
+			t.Error(err)
+		} else if actual.String() != total {
+			t.Errorf("got total tokens %s, expecting %s", actual, total)
+		}
+
+		if actual, err := recorder.TransactedToken(LastDay); err != nil {
+			t.Error(err)
+		} else if actual.String() != transacted {
+			t.Errorf("got transacted tokens %s, expecting %s", actual, transacted)
+		}
+
+		if actual, err := recorder.MintedTokens(""); err != nil {
+			t.Error(err)
+		} else if len(actual) != issuances {
+			t.Errorf("got %d issuances, expecting %d", len(actual), issuances)
+		}
+
+		if cursor, err := recorder.Cursor(); err != nil {
+			t.Error(err)
+		} else if cursor != height+1 {
+			t.Errorf("got cursor %d, expecting %d", cursor, height+1)
+		}
+	}
+
+	//1000 minted + 100 + 100 + 90 rewards - 50 burned
+	check(3, "1240", "500", 2)
+
+	issuances, err := recorder.MintedTokens(LastDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issuances) != 1 || issuances[0].Height != 3 || issuances[0].Burned.String() != "50" {
+		t.Errorf("got issuances %+v in the last day, expecting 50 burned at height 3", issuances)
+	}
+
+	if err := recorder.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+
+	check(1, "1100", "0", 1)
+
+	//recording a block again replaces its values
+	if err := recorder.Record(seriesBlock(t, 2, now, "100", TransactionVersionOne, "300", "300", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	check(2, "1200", "300", 1)
+}
+
+func TestSeriesRecorderSumOverflow(t *testing.T) {
+	recorder, err := NewSeriesRecorder(filepath.Join(t.TempDir(), "series.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	//each value fits an int64, but not their sum
+	const value = "9000000000000000000"
+	now := time.Now().Unix()
+	for h := int64(0); h < 3; h++ {
+		if err := recorder.Record(seriesBlock(t, h, now, value, TransactionVersionOne, value, value, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if total, err := recorder.TotalTokens(); err != nil {
+		t.Error(err)
+	} else if total.String() != "27000000000000000000" {
+		t.Errorf("got total tokens %s, expecting 27000000000000000000", total)
+	}
+
+	//amounts above the int64 range are recorded exactly
+	if err := recorder.Record(seriesBlock(t, 3, now, "100000000000000000000", TransactionVersionOne, "", "0", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if total, err := recorder.TotalTokens(); err != nil {
+		t.Error(err)
+	} else if total.String() != "127000000000000000000" {
+		t.Errorf("got total tokens %s, expecting 127000000000000000000", total)
+	}
+
+	if transacted, err := recorder.TransactedToken(LastHour); err != nil {
+		t.Error(err)
+	} else if transacted.String() != "27000000000000000000" {
+		t.Errorf("got transacted tokens %s, expecting 27000000000000000000", transacted)
+	}
+}
+
+func TestSeriesRecorderUpgrade(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "series.db")
+
+	//the schema of the first version, with integer amounts
+	store, err := OpenStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Migrate(seriesMigrations[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	for h, amounts := range [][3]int64{{0, 1000, 0}, {100, 0, 0}, {100, 0, 50}} {
+		_, err := store.Exec("insert into block (height, timestamp, transactions, reward, minted, burned) values (?, ?, 1, ?, ?, ?);",
+			h, now, amounts[0], amounts[1], amounts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = store.Exec("insert into txn (height, idx, timestamp, input, output, fees, input_addresses, output_addresses) values (?, 0, ?, 200, 190, 10, 1, 1);",
+			h, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := setCursor(store, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	recorder, err := NewSeriesRecorder(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer recorder.Close()
+
+	if total, err := recorder.TotalTokens(); err != nil {
+		t.Error(err)
+	} else if total.String() != "1150" {
+		t.Errorf("got total tokens %s, expecting 1150", total)
+	}
+
+	if transacted, err := recorder.TransactedToken(LastHour); err != nil {
+		t.Error(err)
+	} else if transacted.String() != "600" {
+		t.Errorf("got transacted tokens %s, expecting 600", transacted)
+	}
+
+	//the next block continues the running total
+	if err := recorder.Record(seriesBlock(t, 3, now, "100", TransactionVersionOne, "", "0", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if total, err := recorder.TotalTokens(); err != nil {
+		t.Error(err)
+	} else if total.String() != "1250" {
+		t.Errorf("got total tokens %s, expecting 1250", total)
+	}
+}
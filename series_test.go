@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"testing"
+)
+
+func TestTransactionIssuance(t *testing.T) {
+	cases := []struct {
+		version TransactionVersion
+		height  int64
+		minted  string
+		burned  string
+		fees    string
+	}{
+		{TransactionVersionZero, 1200, "0", "0", "100000000"},
+		{TransactionVersionOne, 1201, "0", "0", "1000000000"},
+		//the minting transactions create their fees
+		{TransactionVersionMinterDefinition, 1300, "1000000000", "0", "1000000000"},
+		{TransactionVersionCoinCreation, 1301, "500001000000000", "0", "1000000000"},
+		{TransactionVersionCoinDestruction, 1302, "0", "999000000000", "1000000000"},
+		//the bot fee is paid like the transaction fee
+		{TransactionVersionBotRegistration, 1400, "0", "0", "11000000000"},
+		{TransactionVersionBotRecordUpdate, 1401, "0", "0", "6000000000"},
+		{TransactionVersionBotNameTransfer, 1402, "0", "0", "11000000000"},
+		{TransactionVersionERC20Conversion, 1500, "0", "200000000000", "1000000000"},
+		{TransactionVersionERC20CoinCreation, 1501, "101000000000", "0", "1000000000"},
+		{TransactionVersionERC20AddressRegistration, 1502, "0", "0", "11000000000"},
+	}
+
+	for _, c := range cases {
+		txn := testTransaction(t, c.version)
+		value := aggregate(txn)
+		minted, burned := issuance(txn, &value, c.height)
+		if minted.String() != c.minted || burned.String() != c.burned || value.Fees.String() != c.fees {
+			t.Errorf("version %d: got %s minted, %s burned and %s fees, expecting %s, %s and %s",
+				c.version, minted, burned, value.Fees, c.minted, c.burned, c.fees)
+		}
+	}
+
+	//the genesis outputs are minted
+	txn := testTransaction(t, TransactionVersionZero)
+	txn.CoinInputOutputs = nil
+	txn.RawTransaction.Data.MinerFees = nil
+	value := aggregate(txn)
+	if minted, burned := issuance(txn, &value, 0); minted.String() != "99900000000" || burned.Sign() != 0 {
+		t.Errorf("got %s minted and %s burned by the genesis transaction, expecting 99900000000 and 0", minted, burned)
+	}
+}
+
+func TestBlockIssuance(t *testing.T) {
+	blk := &Block{Height: 1500, Transactions: []Transaction{
+		*testTransaction(t, TransactionVersionERC20Conversion),
+		*testTransaction(t, TransactionVersionBotNameTransfer),
+	}}
+
+	//the block creator gets the reward and the fees
+	blk.RawBlock.MinerPayouts = InputOutputs{
+		{Value: NewCurrency64(1000000000), UnlockHash: multiSigOwner1},
+		{Value: NewCurrency64(12000000000), UnlockHash: multiSigOwner2},
+	}
+
+	values, issuance, err := blockValues(blk, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 2 {
+		t.Fatalf("got %d transaction values, expecting 2", len(values))
+	}
+
+	if issuance.Reward.String() != "1000000000" || issuance.Minted.Sign() != 0 || issuance.Burned.String() != "200000000000" {
+		t.Errorf("got reward %s, %s minted and %s burned, expecting 1000000000, 0 and 200000000000",
+			issuance.Reward, issuance.Minted, issuance.Burned)
+	}
+}
@@ -0,0 +1,173 @@
+package reporter
+
+import (
+	"bytes"
+	"database/sql"
+	"time"
+
+	influxdb "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+)
+
+//Spool is a durable queue of the batches of points that failed to be written to influxdb. The batches are kept
+//as line protocol in a sqlite database, and replayed in the order they were pushed. The batches that influxdb
+//refuses are moved to a dead letter table, so they don't hold back the next ones.
+type Spool struct {
+	db *sql.DB
+}
+
+//SpoolStats are the size and age of the spool
+type SpoolStats struct {
+	Batches int64
+	Points  int64
+	Bytes   int64
+	//Age is the age of the oldest batch, zero if the spool is empty
+	Age time.Duration
+	//Dead is the number of batches that influxdb refused
+	Dead int64
+}
+
+//NewSpool creates a spool in the sqlite database at p
+func NewSpool(p string) (*Spool, error) {
+	db, err := openSQLite(p)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := `
+	create table if not exists spool (
+		id integer not null primary key autoincrement,
+		created integer not null,
+		points integer not null,
+		lines blob not null
+	);
+
+	create table if not exists spool_dead (
+		id integer not null primary key,
+		created integer not null,
+		failed integer not null,
+		points integer not null,
+		lines blob not null,
+		error text not null
+	);
+	`
+	if _, err := db.Exec(exec); err != nil {
+		return nil, err
+	}
+
+	return &Spool{db: db}, nil
+}
+
+//Push appends a batch to the spool
+func (s *Spool) Push(bp influxdb.BatchPoints) error {
+	var b bytes.Buffer
+	for _, point := range bp.Points() {
+		b.WriteString(point.String())
+		b.WriteByte('\n')
+	}
+
+	_, err := s.db.Exec(
+		"insert into spool (created, points, lines) values (?, ?, ?);",
+		time.Now().Unix(), len(bp.Points()), b.Bytes(),
+	)
+
+	return err
+}
+
+//Replay writes the spooled batches to database in order, a batch is removed from the spool once it's
+//written. It stops at the first write error that can be retried (see IsRetryable), and the remaining
+//batches are replayed on the next call. A batch that fails with another error is moved to the dead letters.
+func (s *Spool) Replay(database string, write func(bp influxdb.BatchPoints) error) error {
+	for {
+		var id int64
+		var lines []byte
+		row := s.db.QueryRow("select id, lines from spool order by id limit 1;")
+		if err := row.Scan(&id, &lines); err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		//points are written with nanoseconds precision
+		points, err := models.ParsePointsWithPrecision(lines, time.Now(), "n")
+		if err == nil {
+			var bp influxdb.BatchPoints
+			if bp, err = influxdb.NewBatchPoints(influxdb.BatchPointsConfig{Database: database}); err != nil {
+				return err
+			}
+
+			for _, point := range points {
+				bp.AddPoint(influxdb.NewPointFrom(point))
+			}
+
+			if err = write(bp); IsRetryable(err) {
+				return err
+			}
+		}
+
+		if err != nil {
+			log.Errorf("moving spooled batch (%d) to the dead letters, it can't be written to influxdb: %s", id, err)
+			if err := s.dead(id, err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if _, err := s.db.Exec("delete from spool where id = ?;", id); err != nil {
+			return err
+		}
+
+		log.Infof("replayed %d spooled points", len(points))
+	}
+}
+
+//dead moves a batch to the dead letters with the error it failed with
+func (s *Spool) dead(id int64, reason error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"insert into spool_dead (id, created, failed, points, lines, error) select id, created, ?, points, lines, ? from spool where id = ?;",
+		time.Now().Unix(), reason.Error(), id,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec("delete from spool where id = ?;", id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//Stats returns the size and age of the spool
+func (s *Spool) Stats() (SpoolStats, error) {
+	row := s.db.QueryRow("select count(*), sum(points), sum(length(lines)), min(created) from spool;")
+
+	var stats SpoolStats
+	var points, size, created sql.NullInt64
+	if err := row.Scan(&stats.Batches, &points, &size, &created); err != nil {
+		return stats, err
+	}
+
+	stats.Points = points.Int64
+	stats.Bytes = size.Int64
+	if created.Valid {
+		stats.Age = time.Since(time.Unix(created.Int64, 0))
+	}
+
+	row = s.db.QueryRow("select count(*) from spool_dead;")
+	err := row.Scan(&stats.Dead)
+	return stats, err
+}
+
+//Close the spool
+func (s *Spool) Close() error {
+	return s.db.Close()
+}
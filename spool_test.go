@@ -0,0 +1,83 @@
+package reporter
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	influxdb "github.com/influxdata/influxdb/client/v2"
+)
+
+func TestSpoolReplay(t *testing.T) {
+	spool, err := NewSpool(filepath.Join(t.TempDir(), "spool.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer spool.Close()
+
+	for h := 0; h < 3; h++ {
+		bp, err := influxdb.NewBatchPoints(influxdb.BatchPointsConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		point, err := influxdb.NewPoint("block", nil, map[string]interface{}{"height": h}, time.Unix(1500000000, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bp.AddPoint(point)
+		if err := spool.Push(bp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//the first batch is refused, the second can be retried
+	var written []int64
+	failures := []error{InfluxError{Code: http.StatusBadRequest}, InfluxError{Code: http.StatusServiceUnavailable}}
+	write := func(bp influxdb.BatchPoints) error {
+		if len(failures) != 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return err
+		}
+
+		fields, err := bp.Points()[0].Fields()
+		if err != nil {
+			return err
+		}
+
+		height, err := number(fields["height"])
+		written = append(written, height)
+		return err
+	}
+
+	if err := spool.Replay("rivine", write); err == nil {
+		t.Error("expecting the retryable error")
+	}
+
+	stats, err := spool.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Batches != 2 || stats.Dead != 1 {
+		t.Errorf("got %d batches and %d dead, expecting 2 and 1", stats.Batches, stats.Dead)
+	}
+
+	if err := spool.Replay("rivine", write); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(written) != 2 || written[0] != 1 || written[1] != 2 {
+		t.Errorf("got written heights %v, expecting [1 2]", written)
+	}
+
+	if stats, err := spool.Stats(); err != nil {
+		t.Error(err)
+	} else if stats.Batches != 0 || stats.Dead != 1 {
+		t.Errorf("got %d batches and %d dead, expecting 0 and 1", stats.Batches, stats.Dead)
+	}
+}
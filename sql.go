@@ -0,0 +1,107 @@
+package reporter
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	cursorSchema = `
+	create table if not exists cursor (
+		id integer not null primary key,
+		height integer not null
+	);
+	`
+)
+
+//querier is implemented by both sql.DB and sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//openSQLite opens a sqlite database in WAL mode, so the API can read while a batch of blocks is being written
+func openSQLite(p string) (*sql.DB, error) {
+	return sql.Open("sqlite3", p+"?_journal_mode=WAL&_busy_timeout=5000")
+}
+
+//stmtTx is a transaction that prepares each query once, and reuses the statement for the rest of the transaction.
+//The queries are rewritten with rebind before they are prepared.
+type stmtTx struct {
+	*sql.Tx
+	stmts  map[string]*sql.Stmt
+	rebind func(string) string
+}
+
+func beginStmtTx(db *sql.DB, rebind func(string) string) (*stmtTx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmtTx{Tx: tx, stmts: make(map[string]*sql.Stmt), rebind: rebind}, nil
+}
+
+func (t *stmtTx) stmt(query string) (*sql.Stmt, error) {
+	if stmt, ok := t.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	//statements prepared on a transaction are closed on commit or rollback
+	stmt, err := t.Tx.Prepare(t.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+
+	t.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (t *stmtTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := t.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Exec(args...)
+}
+
+func (t *stmtTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := t.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Query(args...)
+}
+
+func (t *stmtTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	stmt, err := t.stmt(query)
+	if err != nil {
+		//the unprepared query reports the same error on scan
+		return t.Tx.QueryRow(t.rebind(query), args...)
+	}
+
+	return stmt.QueryRow(args...)
+}
+
+//getCursor returns the height of the next block to record
+func getCursor(q querier) (int64, error) {
+	row := q.QueryRow("select height from cursor where id = 0;")
+	var height int64
+	if err := row.Scan(&height); err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+//setCursor sets the height of the next block to record
+func setCursor(q querier, height int64) error {
+	_, err := q.Exec("insert into cursor (id, height) values (0, ?) on conflict (id) do update set height = excluded.height;", height)
+	return err
+}
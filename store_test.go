@@ -0,0 +1,104 @@
+package reporter
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{"select value from balance where address = ?;", "select value from balance where address = $1;"},
+		{"insert into t (a, b, c) values (?, ?, ?);", "insert into t (a, b, c) values ($1, $2, $3);"},
+		{"select '?' from t where a = ?;", "select '?' from t where a = $1;"},
+		{"select 'it''s ?' from t where a = ?;", "select 'it''s ?' from t where a = $1;"},
+		{`select "what?" from t where a = ?;`, `select "what?" from t where a = $1;`},
+		{"select a from t -- is it?\nwhere a = ? and b = ?;", "select a from t -- is it?\nwhere a = $1 and b = $2;"},
+		{"select a - ? from t where b = -?;", "select a - $1 from t where b = -$2;"},
+		{"select a from t where b = ?-?;", "select a from t where b = $1-$2;"},
+	}
+
+	for _, c := range cases {
+		if actual := (postgresDialect{}).Rebind(c.query); actual != c.expected {
+			t.Errorf("Rebind(%q) = %q, expecting %q", c.query, actual, c.expected)
+		}
+	}
+}
+
+func TestPostgresSchema(t *testing.T) {
+	schema := `
+	create table if not exists history (
+		rowid integer primary key,
+		address text not null,
+		height integer not null
+	);`
+
+	expected := `
+	create table if not exists history (
+		rowid bigserial primary key,
+		address text not null,
+		height bigint not null
+	);`
+
+	if actual := (postgresDialect{}).Schema(schema); actual != expected {
+		t.Errorf("Schema() = %q, expecting %q", actual, expected)
+	}
+
+	if actual := (sqliteDialect{}).Schema(schema); actual != schema {
+		t.Errorf("sqlite schema was changed to %q", actual)
+	}
+}
+
+func TestStoreMigrate(t *testing.T) {
+	store, err := OpenStore("sqlite://" + filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Close()
+
+	migrations := []Migration{
+		{1, "create table a (value integer not null);"},
+		{2, "create table b (value integer not null);"},
+	}
+
+	if err := store.Check(migrations); err == nil {
+		t.Error("expecting an error checking an empty store")
+	}
+
+	if err := store.Migrate(migrations[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Check(migrations); err == nil || !strings.Contains(err.Error(), "at version 1, expecting 2") {
+		t.Errorf("got error %v checking a store missing a migration", err)
+	}
+
+	//applied migrations are skipped
+	if err := store.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Check(migrations); err != nil {
+		t.Error(err)
+	}
+
+	//a failed migration is rolled back with its version
+	failed := append(migrations, Migration{3, "create table c (value integer not null); create table a (value text);"})
+	if err := store.Migrate(failed); err == nil || !strings.HasPrefix(err.Error(), "migration (3): ") {
+		t.Errorf("got error %v applying a failed migration", err)
+	}
+
+	if version, err := store.Version(); err != nil {
+		t.Error(err)
+	} else if version != 2 {
+		t.Errorf("got version %d after a failed migration, expecting 2", version)
+	}
+
+	if _, err := store.Exec("select count(*) from c;"); err == nil {
+		t.Error("the failed migration changes were kept")
+	}
+}
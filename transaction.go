@@ -0,0 +1,259 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//TransactionVersion is the version of a transaction, which defines the content of its data
+type TransactionVersion int
+
+const (
+	//TransactionVersionZero legacy transaction, where the inputs have an unlocker instead of a fulfillment
+	//and the outputs an unlock hash instead of a condition
+	TransactionVersionZero TransactionVersion = 0
+	//TransactionVersionOne standard transaction
+	TransactionVersionOne TransactionVersion = 1
+
+	//TransactionVersionMinterDefinition redefines the condition of the coin minters
+	TransactionVersionMinterDefinition TransactionVersion = 128
+	//TransactionVersionCoinCreation creates new coins
+	TransactionVersionCoinCreation TransactionVersion = 129
+	//TransactionVersionCoinDestruction burns the coins of its inputs
+	TransactionVersionCoinDestruction TransactionVersion = 130
+
+	//TransactionVersionBotRegistration registers a 3bot
+	TransactionVersionBotRegistration TransactionVersion = 144
+	//TransactionVersionBotRecordUpdate updates the record of a 3bot
+	TransactionVersionBotRecordUpdate TransactionVersion = 145
+	//TransactionVersionBotNameTransfer transfers names between two 3bots
+	TransactionVersionBotNameTransfer TransactionVersion = 146
+
+	//TransactionVersionERC20Conversion converts coins to ERC20 tokens, the coins are burned
+	TransactionVersionERC20Conversion TransactionVersion = 208
+	//TransactionVersionERC20CoinCreation creates coins from converted ERC20 tokens
+	TransactionVersionERC20CoinCreation TransactionVersion = 209
+	//TransactionVersionERC20AddressRegistration registers the ERC20 address of a wallet address
+	TransactionVersionERC20AddressRegistration TransactionVersion = 210
+)
+
+//UnknownTransactionVersionError is returned when decoding a transaction of a version we don't know how to
+//account for, it's terminal since the same block will always fail
+type UnknownTransactionVersionError struct {
+	Version TransactionVersion
+}
+
+func (e UnknownTransactionVersionError) Error() string {
+	return fmt.Sprintf("unknown transaction version %d (0x%02x)", e.Version, int(e.Version))
+}
+
+//Retryable always returns false
+func (e UnknownTransactionVersionError) Retryable() bool {
+	return false
+}
+
+//BotSignature is the identification of a 3bot in a transaction
+type BotSignature struct {
+	ID        int64  `json:"id,omitempty"`
+	PublicKey string `json:"publickey,omitempty"`
+	Signature string `json:"signature"`
+}
+
+//BotUpdate are the addresses or names added and removed from a 3bot record
+type BotUpdate struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+//TransactionData has the fields of all the transaction versions, only the fields of the transaction
+//version are set. Once decoded the coin inputs, coin outputs and miner fees also include their
+//version specific equivalents, so the recorders don't need to know about the transaction versions:
+//
+//  - the refund coin output of the coin destruction, 3bot and ERC20 transactions is a coin output
+//  - the created coins of the ERC20 coin creation transaction are a coin output
+//  - the transaction fee of the 3bot and ERC20 transactions is a miner fee
+type TransactionData struct {
+	CoinInputs        []CoinInput  `json:"coininputs"`
+	CoinOutputs       InputOutputs `json:"coinoutputs"`
+	BlockStakeInputs  []CoinInput  `json:"blockstakeinputs"`
+	BlockStakeOutputs InputOutputs `json:"blockstakeoutputs"`
+	MinerFees         []Currency   `json:"minerfees"`
+	ArbitraryData     []byte       `json:"arbitrarydata"`
+
+	//coin minting (versions 128 and 129)
+	Nonce           []byte       `json:"nonce"`
+	MintFulfillment *Fulfillment `json:"mintfulfillment"`
+	MintCondition   *Condition   `json:"mintcondition"`
+
+	//coin destruction, 3bot and ERC20 transactions
+	RefundCoinOutput *InputOutput `json:"refundcoinoutput"`
+	TransactionFee   *Currency    `json:"txfee"`
+
+	//3bot transactions (versions 144, 145 and 146)
+	BotID            int64         `json:"id"`
+	NetworkAddresses []string      `json:"-"`
+	Names            []string      `json:"-"`
+	AddressUpdate    *BotUpdate    `json:"-"`
+	NameUpdate       *BotUpdate    `json:"-"`
+	NumberOfMonths   int           `json:"nrofmonths"`
+	Identification   *BotSignature `json:"identification"`
+	Signature        string        `json:"signature"`
+	Sender           *BotSignature `json:"sender"`
+	Receiver         *BotSignature `json:"receiver"`
+	RegistrationFee  *Currency     `json:"regfee"`
+
+	//ERC20 transactions (versions 208, 209 and 210), the address is the ERC20 address on conversion, and
+	//the address receiving the created coins on coin creation
+	Address       string    `json:"address"`
+	Value         *Currency `json:"value"`
+	BlockID       string    `json:"blockid"`
+	TransactionID string    `json:"txid"`
+	PublicKey     string    `json:"pubkey"`
+	TFTAddress    string    `json:"tftaddress"`
+	ERC20Address  string    `json:"erc20address"`
+}
+
+//RawTransaction struct
+type RawTransaction struct {
+	Version TransactionVersion `json:"version"`
+	Data    TransactionData    `json:"data"`
+
+	//data as returned by the explorer, so the transaction encodes back to the same json
+	raw json.RawMessage
+}
+
+//UnmarshalJSON decodes the transaction data according to its version
+func (t *RawTransaction) UnmarshalJSON(text []byte) error {
+	var body struct {
+		Version TransactionVersion `json:"version"`
+		Data    json.RawMessage    `json:"data"`
+	}
+
+	if err := json.Unmarshal(text, &body); err != nil {
+		return err
+	}
+
+	return t.decode(body.Version, body.Data)
+}
+
+//MarshalJSON encodes the transaction as it was decoded
+func (t RawTransaction) MarshalJSON() ([]byte, error) {
+	raw := t.raw
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(t.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(struct {
+		Version TransactionVersion `json:"version"`
+		Data    json.RawMessage    `json:"data"`
+	}{t.Version, raw})
+}
+
+func (t *RawTransaction) decode(version TransactionVersion, data json.RawMessage) error {
+	t.Version = version
+	t.Data = TransactionData{}
+	t.raw = append(json.RawMessage(nil), data...)
+
+	switch version {
+	case TransactionVersionZero, TransactionVersionOne,
+		TransactionVersionMinterDefinition, TransactionVersionCoinCreation, TransactionVersionCoinDestruction,
+		TransactionVersionERC20Conversion, TransactionVersionERC20CoinCreation, TransactionVersionERC20AddressRegistration:
+		if err := json.Unmarshal(data, &t.Data); err != nil {
+			return dataError(err, "transaction version %d", version)
+		}
+	case TransactionVersionBotRegistration, TransactionVersionBotRecordUpdate, TransactionVersionBotNameTransfer:
+		if err := t.decodeBot(data); err != nil {
+			return dataError(err, "transaction version %d", version)
+		}
+	default:
+		return UnknownTransactionVersionError{Version: version}
+	}
+
+	if version == TransactionVersionERC20CoinCreation {
+		if err := ValidateUnlockHash(t.Data.Address); err != nil {
+			return dataError(err, "transaction version %d: address", version)
+		}
+	}
+
+	t.normalize()
+	return nil
+}
+
+//decodeBot decodes the 3bot transactions, where the addresses and names are lists on registration
+//and updates on record update
+func (t *RawTransaction) decodeBot(data json.RawMessage) error {
+	if err := json.Unmarshal(data, &t.Data); err != nil {
+		return err
+	}
+
+	var bot struct {
+		Addresses json.RawMessage `json:"addresses"`
+		Names     json.RawMessage `json:"names"`
+	}
+
+	if err := json.Unmarshal(data, &bot); err != nil {
+		return err
+	}
+
+	if t.Version == TransactionVersionBotRecordUpdate {
+		for _, field := range []struct {
+			raw    json.RawMessage
+			update **BotUpdate
+		}{{bot.Addresses, &t.Data.AddressUpdate}, {bot.Names, &t.Data.NameUpdate}} {
+			if len(field.raw) == 0 || string(field.raw) == "null" {
+				continue
+			}
+
+			*field.update = &BotUpdate{}
+			if err := json.Unmarshal(field.raw, *field.update); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, field := range []struct {
+		raw  json.RawMessage
+		list *[]string
+	}{{bot.Addresses, &t.Data.NetworkAddresses}, {bot.Names, &t.Data.Names}} {
+		if len(field.raw) == 0 || string(field.raw) == "null" {
+			continue
+		}
+
+		if err := json.Unmarshal(field.raw, field.list); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//normalize adds the version specific coin inputs, outputs and fees to the common ones
+func (t *RawTransaction) normalize() {
+	data := &t.Data
+	for i := range data.CoinInputs {
+		input := &data.CoinInputs[i]
+		if input.Unlocker != nil && input.Fulfillment.Type == NilFulfillment {
+			input.Fulfillment = Fulfillment{Type: input.Unlocker.Type, Data: input.Unlocker.Fulfillment}
+		}
+	}
+
+	if t.Version == TransactionVersionERC20CoinCreation && data.Value != nil {
+		data.CoinOutputs = append(data.CoinOutputs, InputOutput{
+			Value:     *data.Value,
+			Condition: NewCondition(UnlockHashConditionData{UnlockHash: data.Address}),
+		})
+	}
+
+	if data.RefundCoinOutput != nil {
+		data.CoinOutputs = append(data.CoinOutputs, *data.RefundCoinOutput)
+	}
+
+	if data.TransactionFee != nil {
+		data.MinerFees = append(data.MinerFees, *data.TransactionFee)
+	}
+}
@@ -0,0 +1,360 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	testFulfillment = `{"type": 1, "data": {
+		"publickey": "ed25519:d285f92d6d449d9abb27f4c6cf82713cec0696d62b8c123f1627e054dc6d7780",
+		"signature": "f76b3d6d1e4cb6b7e0bd3ca4d4c6f3d77ba9e4c8cd8bfad5fe1d7c1e2c9b8b7e6a4f1b7c5f9d4e0c4d8a3d3b0c7b2b7d4f9e1c2a3b4c5d6e7f8091a2b3c4d5e6f7"
+	}}`
+	testBotSignature = "a3d1c1f3b0e9d8c7b6a59483726150f9e8d7c6b5a4938271605f4e3d2c1b0a99887766554433221100ffeeddccbbaa99887766554433221100ffeeddccbbaa0f"
+)
+
+//testTransactions are explorer transactions of each version, with the coin inputs they spend
+var testTransactions = map[TransactionVersion]string{
+	TransactionVersionZero: fmt.Sprintf(`{
+		"id": "5b7e1d0f6ab6d56e2c1d2cb4b1ac87df2d4b5c3e2f6a8b9c0d1e2f3a4b5c6d7e", "height": 1200,
+		"rawtransaction": {"version": 0, "data": {
+			"coininputs": [{"parentid": "1f2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708", "unlocker": {
+				"type": 1,
+				"condition": {"publickey": "ed25519:d285f92d6d449d9abb27f4c6cf82713cec0696d62b8c123f1627e054dc6d7780"},
+				"fulfillment": {"signature": "f76b3d6d1e4cb6b7e0bd3ca4d4c6f3d77ba9e4c8cd8bfad5fe1d7c1e2c9b8b7e"}
+			}}],
+			"coinoutputs": [
+				{"value": "99000000000", "unlockhash": "%[1]s"},
+				{"value": "900000000", "unlockhash": "%[2]s"}
+			],
+			"minerfees": ["100000000"],
+			"arbitrarydata": "SGVsbG8gdGZjaGFpbg=="
+		}},
+		"coininputoutputs": [{"value": "100000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}, "unlockhash": "%[2]s"}],
+		"coinoutputids": ["8f6d1c2e3b4a59687f6e5d4c3b2a19087f6e5d4c3b2a19087f6e5d4c3b2a1908", "9a8b7c6d5e4f30211f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a7988"],
+		"coinoutputunlockhashes": ["%[1]s", "%[2]s"]
+	}`, multiSigOwner1, multiSigOwner2),
+
+	TransactionVersionOne: fmt.Sprintf(`{
+		"id": "6c8f2e1a7bc7e67f3d2e3dc5c2bd98e03e5c6d4f3a7b9c0d1e2f3a4b5c6d7e8f", "height": 1201,
+		"rawtransaction": {"version": 1, "data": {
+			"coininputs": [{"parentid": "8f6d1c2e3b4a59687f6e5d4c3b2a19087f6e5d4c3b2a19087f6e5d4c3b2a1908", "fulfillment": %[3]s}],
+			"coinoutputs": [
+				{"value": "50000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}},
+				{"value": "48000000000", "condition": {"type": 3, "data": {"locktime": 1550000000, "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}}}}
+			],
+			"blockstakeinputs": [{"parentid": "2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f70819", "fulfillment": %[3]s}],
+			"blockstakeoutputs": [{"value": "10", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}}],
+			"minerfees": ["1000000000"]
+		}},
+		"coininputoutputs": [{"value": "99000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}, "unlockhash": "%[1]s"}],
+		"coinoutputids": ["ab8c7d6e5f4031221f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a7999", "bc9d8e7f6051423320304e5d6c7b8a9920304e5d6c7b8a9920304e5d6c7b8aaa"],
+		"coinoutputunlockhashes": ["%[2]s", "%[1]s"],
+		"blockstakeinputoutputs": [{"value": "10", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}, "unlockhash": "%[1]s"}],
+		"blockstakeoutputids": ["cdae9f8071625344313f5f6e7d8c9baa313f5f6e7d8c9baa313f5f6e7d8c9bbb"],
+		"blockstakeunlockhashes": ["%[1]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment),
+
+	TransactionVersionMinterDefinition: fmt.Sprintf(`{
+		"id": "7d9a3f2b8cd8f7804e3f4ed6d3ce09f14f6d7e5a4b8c0d1e2f3a4b5c6d7e8f90", "height": 1300,
+		"rawtransaction": {"version": 128, "data": {
+			"nonce": "FoAiO8vN2eU=",
+			"mintfulfillment": %[3]s,
+			"mintcondition": {"type": 4, "data": {"unlockhashes": ["%[1]s", "%[2]s"], "minimumsignaturecount": 2}},
+			"minerfees": ["1000000000"],
+			"arbitrarydata": "bWludGVyIGRlZmluaXRpb24="
+		}}
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment),
+
+	TransactionVersionCoinCreation: fmt.Sprintf(`{
+		"id": "8eab4a3c9de908915f4a5fe7e4df1a025a7e8f6b5c9d1e2f3a4b5c6d7e8f9001", "height": 1301,
+		"rawtransaction": {"version": 129, "data": {
+			"nonce": "1oQFzIwsLs8=",
+			"mintfulfillment": %[3]s,
+			"coinoutputs": [{"value": "500000000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}}],
+			"minerfees": ["1000000000"],
+			"arbitrarydata": "bW9uZXkgZnJvbSB0aGUgc2t5"
+		}},
+		"coinoutputids": ["debfa09182736455424f6f7e8d9cacbb424f6f7e8d9cacbb424f6f7e8d9caccc"],
+		"coinoutputunlockhashes": ["%[1]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment),
+
+	TransactionVersionCoinDestruction: fmt.Sprintf(`{
+		"id": "9fbc5b4d0ef019026a5b6af8f5e02b136b8f9a7c6d0e2f3a4b5c6d7e8f900112", "height": 1302,
+		"rawtransaction": {"version": 130, "data": {
+			"coininputs": [{"parentid": "debfa09182736455424f6f7e8d9cacbb424f6f7e8d9cacbb424f6f7e8d9caccc", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "499000000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}},
+			"minerfees": ["1000000000"]
+		}},
+		"coininputoutputs": [{"value": "500000000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}, "unlockhash": "%[1]s"}],
+		"coinoutputids": ["efc0b1a293847566535f7f8f9eadbdcc535f7f8f9eadbdcc535f7f8f9eadbddd"],
+		"coinoutputunlockhashes": ["%[1]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment),
+
+	TransactionVersionBotRegistration: fmt.Sprintf(`{
+		"id": "a0cd6c5e1f012a137b6c7b09a6f13c247c90ab8d7e1f3a4b5c6d7e8f90011223", "height": 1400,
+		"rawtransaction": {"version": 144, "data": {
+			"addresses": ["91.198.174.192", "example.org"],
+			"names": ["chatbot.example"],
+			"nrofmonths": 1,
+			"txfee": "1000000000",
+			"coininputs": [{"parentid": "ab8c7d6e5f4031221f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a7999", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "39000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}},
+			"identification": {
+				"publickey": "ed25519:00bde9571b30e1742c41fcca8c730183402d967df5b17b5f4ced22c677806614",
+				"signature": "%[4]s"
+			}
+		}},
+		"coininputoutputs": [{"value": "50000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}, "unlockhash": "%[2]s"}],
+		"coinoutputids": ["f0d1c2b3a4958677646f8f9faebecedd646f8f9faebecedd646f8f9faebeceee"],
+		"coinoutputunlockhashes": ["%[2]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment, testBotSignature),
+
+	TransactionVersionBotRecordUpdate: fmt.Sprintf(`{
+		"id": "b1de7d6f20123b248c7d8c1ab7024d358da1bc9e8f2a4b5c6d7e8f9001122334", "height": 1401,
+		"rawtransaction": {"version": 145, "data": {
+			"id": 1,
+			"addresses": {"add": ["example.com"], "remove": ["example.org"]},
+			"names": {"add": ["chatbot.example.com"], "remove": null},
+			"nrofmonths": 0,
+			"txfee": "1000000000",
+			"coininputs": [{"parentid": "f0d1c2b3a4958677646f8f9faebecedd646f8f9faebecedd646f8f9faebeceee", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "33000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}},
+			"signature": "%[4]s"
+		}},
+		"coininputoutputs": [{"value": "39000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}, "unlockhash": "%[2]s"}],
+		"coinoutputids": ["01e2d3c4b5a69788757f9fafbfcfdfee757f9fafbfcfdfee757f9fafbfcfdfff"],
+		"coinoutputunlockhashes": ["%[2]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment, testBotSignature),
+
+	TransactionVersionBotNameTransfer: fmt.Sprintf(`{
+		"id": "c2ef8e7031234c359d8e9d2bc8135e469eb2cdaf9a3b5c6d7e8f900112233445", "height": 1402,
+		"rawtransaction": {"version": 146, "data": {
+			"sender": {"id": 1, "signature": "%[4]s"},
+			"receiver": {"id": 2, "signature": "%[4]s"},
+			"names": ["chatbot.example"],
+			"txfee": "1000000000",
+			"coininputs": [{"parentid": "01e2d3c4b5a69788757f9fafbfcfdfee757f9fafbfcfdfee757f9fafbfcfdfff", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "22000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}}
+		}},
+		"coininputoutputs": [{"value": "33000000000", "condition": {"type": 1, "data": {"unlockhash": "%[2]s"}}, "unlockhash": "%[2]s"}],
+		"coinoutputids": ["12f3e4d5c6b7a8998680a0b0c0d0e0ff8680a0b0c0d0e0ff8680a0b0c0d0e000"],
+		"coinoutputunlockhashes": ["%[2]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment, testBotSignature),
+
+	TransactionVersionERC20Conversion: fmt.Sprintf(`{
+		"id": "d3f09f8142345d46ae9fae3cd9246f57afc3dea0ab4c6d7e8f90011223344556", "height": 1500,
+		"rawtransaction": {"version": 208, "data": {
+			"address": "0x828de486adc50aa52dab52a2ec284bcac75be211",
+			"value": "200000000000",
+			"txfee": "1000000000",
+			"coininputs": [{"parentid": "ab8c7d6e5f4031221f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c5b6a7999", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "99000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}}
+		}},
+		"coininputoutputs": [{"value": "300000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}, "unlockhash": "%[1]s"}],
+		"coinoutputids": ["23f4e5d6c7b8a9a99791b1c1d1e1f1009791b1c1d1e1f1009791b1c1d1e1f100"],
+		"coinoutputunlockhashes": ["%[1]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment),
+
+	TransactionVersionERC20CoinCreation: fmt.Sprintf(`{
+		"id": "e4010a9253456e57bfa0bf4dea357068b0d4efb1bc5d7e8f9001122334455667", "height": 1501,
+		"rawtransaction": {"version": 209, "data": {
+			"address": "%[2]s",
+			"value": "100000000000",
+			"txfee": "1000000000",
+			"blockid": "0x0b3e8f2c4a1d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f",
+			"txid": "0x1c4f9a3d5b2e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a"
+		}},
+		"coinoutputids": ["3405f6e7d8c9babaa802c2d2e2f20211a802c2d2e2f20211a802c2d2e2f20211"],
+		"coinoutputunlockhashes": ["%[2]s"]
+	}`, multiSigOwner1, multiSigOwner2),
+
+	TransactionVersionERC20AddressRegistration: fmt.Sprintf(`{
+		"id": "f5121ba364567f68c0b1c05efb468179c1e5f0c2cd6e8f900112233445566778", "height": 1502,
+		"rawtransaction": {"version": 210, "data": {
+			"pubkey": "ed25519:d285f92d6d449d9abb27f4c6cf82713cec0696d62b8c123f1627e054dc6d7780",
+			"tftaddress": "%[1]s",
+			"erc20address": "0x828de486adc50aa52dab52a2ec284bcac75be211",
+			"signature": "%[4]s",
+			"regfee": "10000000000",
+			"txfee": "1000000000",
+			"coininputs": [{"parentid": "23f4e5d6c7b8a9a99791b1c1d1e1f1009791b1c1d1e1f1009791b1c1d1e1f100", "fulfillment": %[3]s}],
+			"refundcoinoutput": {"value": "88000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}}
+		}},
+		"coininputoutputs": [{"value": "99000000000", "condition": {"type": 1, "data": {"unlockhash": "%[1]s"}}, "unlockhash": "%[1]s"}],
+		"coinoutputids": ["4516a7f8e9dacbcbb913d3e3f3031322b913d3e3f3031322b913d3e3f3031322"],
+		"coinoutputunlockhashes": ["%[1]s"]
+	}`, multiSigOwner1, multiSigOwner2, testFulfillment, testBotSignature),
+}
+
+//testTransaction decodes the test transaction of the given version
+func testTransaction(t *testing.T, version TransactionVersion) *Transaction {
+	var txn Transaction
+	if err := json.Unmarshal([]byte(testTransactions[version]), &txn); err != nil {
+		t.Fatalf("transaction version %d: %v", version, err)
+	}
+
+	return &txn
+}
+
+//currencies returns the values as a space separated string
+func currencies(values ...Currency) string {
+	var s []string
+	for _, value := range values {
+		s = append(s, value.String())
+	}
+
+	return strings.Join(s, " ")
+}
+
+func TestTransactionNormalize(t *testing.T) {
+	cases := []struct {
+		version TransactionVersion
+		//inputs are the fulfillment types of the coin inputs
+		inputs []FulfillmentType
+		//outputs and fees are the values of the coin outputs and miner fees once normalized
+		outputs string
+		fees    string
+	}{
+		{TransactionVersionZero, []FulfillmentType{SingleSignatureFulfillment}, "99000000000 900000000", "100000000"},
+		{TransactionVersionOne, []FulfillmentType{SingleSignatureFulfillment}, "50000000000 48000000000", "1000000000"},
+		{TransactionVersionMinterDefinition, nil, "", "1000000000"},
+		{TransactionVersionCoinCreation, nil, "500000000000000", "1000000000"},
+		//the refund is a coin output
+		{TransactionVersionCoinDestruction, []FulfillmentType{SingleSignatureFulfillment}, "499000000000000", "1000000000"},
+		//the transaction fee is a miner fee
+		{TransactionVersionBotRegistration, []FulfillmentType{SingleSignatureFulfillment}, "39000000000", "1000000000"},
+		{TransactionVersionBotRecordUpdate, []FulfillmentType{SingleSignatureFulfillment}, "33000000000", "1000000000"},
+		{TransactionVersionBotNameTransfer, []FulfillmentType{SingleSignatureFulfillment}, "22000000000", "1000000000"},
+		{TransactionVersionERC20Conversion, []FulfillmentType{SingleSignatureFulfillment}, "99000000000", "1000000000"},
+		//the created coins are a coin output
+		{TransactionVersionERC20CoinCreation, nil, "100000000000", "1000000000"},
+		{TransactionVersionERC20AddressRegistration, []FulfillmentType{SingleSignatureFulfillment}, "88000000000", "1000000000"},
+	}
+
+	if len(cases) != len(testTransactions) {
+		t.Fatalf("got %d cases, expecting one per test transaction (%d)", len(cases), len(testTransactions))
+	}
+
+	for _, c := range cases {
+		txn := testTransaction(t, c.version)
+		data := &txn.RawTransaction.Data
+		if txn.RawTransaction.Version != c.version {
+			t.Errorf("got version %d, expecting %d", txn.RawTransaction.Version, c.version)
+		}
+
+		var inputs []FulfillmentType
+		for _, input := range data.CoinInputs {
+			inputs = append(inputs, input.Fulfillment.Type)
+		}
+
+		if fmt.Sprint(inputs) != fmt.Sprint(c.inputs) {
+			t.Errorf("version %d: got inputs %v, expecting %v", c.version, inputs, c.inputs)
+		}
+
+		var outputs []Currency
+		for _, output := range data.CoinOutputs {
+			outputs = append(outputs, output.Value)
+		}
+
+		if actual := currencies(outputs...); actual != c.outputs {
+			t.Errorf("version %d: got outputs '%s', expecting '%s'", c.version, actual, c.outputs)
+		}
+
+		if actual := currencies(data.MinerFees...); actual != c.fees {
+			t.Errorf("version %d: got fees '%s', expecting '%s'", c.version, actual, c.fees)
+		}
+
+		//the transaction encodes back to the explorer json
+		text, err := json.Marshal(txn.RawTransaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var raw RawTransaction
+		if err := json.Unmarshal(text, &raw); err != nil {
+			t.Errorf("version %d: %v", c.version, err)
+		} else if currencies(raw.Data.MinerFees...) != c.fees {
+			t.Errorf("version %d: got fees '%s' once encoded, expecting '%s'", c.version, currencies(raw.Data.MinerFees...), c.fees)
+		}
+	}
+}
+
+func TestTransactionVersionFields(t *testing.T) {
+	legacy := testTransaction(t, TransactionVersionZero).RawTransaction.Data
+	if unlocker := legacy.CoinInputs[0].Unlocker; unlocker == nil || unlocker.Type != SingleSignatureFulfillment {
+		t.Errorf("got legacy unlocker %+v, expecting a single signature", unlocker)
+	}
+
+	if string(legacy.ArbitraryData) != "Hello tfchain" {
+		t.Errorf("got arbitrary data '%s'", legacy.ArbitraryData)
+	}
+
+	standard := testTransaction(t, TransactionVersionOne)
+	if len(standard.RawTransaction.Data.BlockStakeInputs) != 1 || len(standard.RawTransaction.Data.BlockStakeOutputs) != 1 ||
+		len(standard.BlockStakeInputOutputs) != 1 {
+		t.Error("expecting the block stake inputs and outputs")
+	}
+
+	definition := testTransaction(t, TransactionVersionMinterDefinition).RawTransaction.Data
+	if definition.MintCondition == nil || definition.MintCondition.Type != MultiSignatureCondition || definition.MintFulfillment == nil {
+		t.Errorf("got mint condition %v, expecting a multisignature", definition.MintCondition)
+	}
+
+	registration := testTransaction(t, TransactionVersionBotRegistration).RawTransaction.Data
+	if strings.Join(registration.NetworkAddresses, " ") != "91.198.174.192 example.org" ||
+		strings.Join(registration.Names, " ") != "chatbot.example" || registration.NumberOfMonths != 1 {
+		t.Errorf("got 3bot addresses %v, names %v and %d months", registration.NetworkAddresses, registration.Names, registration.NumberOfMonths)
+	}
+
+	update := testTransaction(t, TransactionVersionBotRecordUpdate).RawTransaction.Data
+	if update.BotID != 1 || update.AddressUpdate == nil || update.NameUpdate == nil ||
+		strings.Join(update.AddressUpdate.Remove, " ") != "example.org" || strings.Join(update.NameUpdate.Add, " ") != "chatbot.example.com" {
+		t.Errorf("got 3bot %d updates %+v and %+v", update.BotID, update.AddressUpdate, update.NameUpdate)
+	}
+
+	transfer := testTransaction(t, TransactionVersionBotNameTransfer).RawTransaction.Data
+	if transfer.Sender == nil || transfer.Sender.ID != 1 || transfer.Receiver == nil || transfer.Receiver.ID != 2 {
+		t.Errorf("got 3bot name transfer from %+v to %+v", transfer.Sender, transfer.Receiver)
+	}
+
+	conversion := testTransaction(t, TransactionVersionERC20Conversion).RawTransaction.Data
+	if conversion.Value == nil || conversion.Value.String() != "200000000000" || conversion.Address != "0x828de486adc50aa52dab52a2ec284bcac75be211" {
+		t.Errorf("got conversion of %v to '%s'", conversion.Value, conversion.Address)
+	}
+
+	creation := testTransaction(t, TransactionVersionERC20CoinCreation).RawTransaction.Data
+	if unlock, err := creation.CoinOutputs[0].Condition.UnlockHashData(); err != nil || unlock.UnlockHash != multiSigOwner2 {
+		t.Errorf("got created coins for %+v (%v), expecting %s", unlock, err, multiSigOwner2)
+	}
+
+	registry := testTransaction(t, TransactionVersionERC20AddressRegistration).RawTransaction.Data
+	if registry.RegistrationFee == nil || registry.RegistrationFee.String() != "10000000000" || registry.TFTAddress != multiSigOwner1 {
+		t.Errorf("got registration of '%s' with fee %v", registry.TFTAddress, registry.RegistrationFee)
+	}
+}
+
+func TestUnknownTransactionVersion(t *testing.T) {
+	text := `{"id": "txn", "height": 1, "rawtransaction": {"version": 3, "data": {"coinoutputs": []}}}`
+
+	var txn Transaction
+	err := json.Unmarshal([]byte(text), &txn)
+	derr, ok := err.(DataError)
+	if !ok {
+		t.Fatalf("got error %v, expecting a data error", err)
+	}
+
+	if verr, ok := derr.Err.(UnknownTransactionVersionError); !ok || verr.Version != 3 {
+		t.Errorf("got error %v, expecting an unknown transaction version error", derr.Err)
+	}
+
+	if expected := "transaction 'txn': unknown transaction version 3 (0x03)"; err.Error() != expected {
+		t.Errorf("got error %q, expecting %q", err, expected)
+	}
+
+	if IsRetryable(err) {
+		t.Error("an unknown transaction version must not be retried")
+	}
+}